@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"rabbit-shorten-url/internal/auth"
+	"rabbit-shorten-url/internal/db/mysql"
+	"strings"
+)
+
+// admin-user create admin user or rotate its password and change its role,
+// password is read from stdin when -password is not given so it does not stay in shell history,
+// role of existing user is changed without its password when only -role is given
+func main() {
+	username := flag.String("username", "", "username of admin user")
+	password := flag.String("password", "", "new password, read from stdin when it is empty and user is new or -role is not given")
+	role := flag.String("role", "", "role of admin user (viewer, editor or owner), new user is viewer when it is empty")
+	flag.Parse()
+
+	db := mysql.New(mysql.Config{
+		Username: os.Getenv("DB_USERNAME"),
+		Password: os.Getenv("DB_PASSWORD"),
+		Database: os.Getenv("DB_DATABASE"),
+		Ip:       os.Getenv("DB_HOST"),
+		Port:     os.Getenv("DB_PORT"),
+	})
+	dbClient, err := db.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close(dbClient)
+
+	authService := auth.New(auth.NewGormRepository(dbClient))
+	if *role != "" && *password == "" {
+		err := authService.SetRole(*username, *role)
+		if err == nil {
+			fmt.Printf("Role of %s has been set to %s\n", *username, *role)
+			return
+		} else if !errors.Is(err, auth.ErrNotFound) {
+			log.Fatal(err)
+		}
+		// new user needs a password
+	}
+
+	if *password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatal(err)
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	if err := authService.SetPassword(*username, *password); err != nil {
+		log.Fatal(err)
+	}
+	if *role != "" {
+		if err := authService.SetRole(*username, *role); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fmt.Printf("Password of %s has been set\n", *username)
+}
@@ -2,20 +2,30 @@ package main
 
 import (
 	"fmt"
+	"github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/basicauth"
-	"github.com/gofiber/fiber/v2/middleware/cache"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"gorm.io/gorm"
+	"html/template"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"rabbit-shorten-url/internal/auth"
 	"rabbit-shorten-url/internal/db/mysql"
+	"rabbit-shorten-url/internal/ratelimit"
 	"rabbit-shorten-url/internal/url"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
 func main() {
+	var repo url.Repository
+	var authRepo auth.Repository
+	var dbClient *gorm.DB
 
 	db := mysql.New(mysql.Config{
 		Username: os.Getenv("DB_USERNAME"),
@@ -24,56 +34,422 @@ func main() {
 		Ip:       os.Getenv("DB_HOST"),
 		Port:     os.Getenv("DB_PORT"),
 	})
-	dbClient, err := db.Connect()
+
+	// STORAGE=memory is used for local development without mysql
+	if os.Getenv("STORAGE") == "memory" {
+		repo = url.NewMemoryRepository()
+		authRepo = auth.NewMemoryRepository()
+	} else {
+		var err error
+		dbClient, err = db.Connect()
+		if err != nil {
+			log.Fatal(err)
+		}
+		repo = url.NewGormRepository(dbClient)
+		authRepo = auth.NewGormRepository(dbClient)
+	}
+
+	// ADMIN_USERNAME and ADMIN_PASSWORD bootstrap first admin user, existing password is not changed
+	authService := auth.New(authRepo)
+	if username := os.Getenv("ADMIN_USERNAME"); username != "" {
+		if err := authService.EnsureUser(username, os.Getenv("ADMIN_PASSWORD")); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// IP_HASH_SALT is the key of hashed client ip, hash of ipv4 address without secret key could be reversed
+	ipHashSalt := os.Getenv("IP_HASH_SALT")
+	if ipHashSalt == "" {
+		log.Fatal("IP_HASH_SALT is required")
+	}
+
+	codeLength, err := strconv.Atoi(getEnv("CODE_LENGTH", "8"))
 	if err != nil {
 		log.Fatal(err)
 	}
+	codeGenerator, err := url.NewCodeGenerator(os.Getenv("CODE_STRATEGY"), codeLength, os.Getenv("CODE_SECRET"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// HIT_FLUSH_INTERVAL=0 writes hits and clicks synchronously on every redirect
+	var hitCounter *url.HitCounter
+	hitFlushInterval, err := time.ParseDuration(getEnv("HIT_FLUSH_INTERVAL", "5s"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if hitFlushInterval > 0 {
+		hitCounter = url.NewHitCounter(repo, hitFlushInterval)
+		hitCounter.Start()
+	}
 
-	app := Setup(dbClient)
+	// ERROR_PAGE=default uses built-in html error page, other value is path to template file
+	var errorPage *template.Template
+	switch page := os.Getenv("ERROR_PAGE"); page {
+	case "":
+	case "default":
+		errorPage = url.DefaultErrorPage()
+	default:
+		if errorPage, err = url.LoadErrorPage(page); err != nil {
+			log.Fatal(err)
+		}
+	}
 
+	// CACHE_TTL=0 disables cache of redirect
+	var resolveCache *url.ResolveCache
+	cacheTtl, err := time.ParseDuration(getEnv("CACHE_TTL", "5m"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cacheSize, err := strconv.Atoi(getEnv("CACHE_SIZE", "10000"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cacheTtl > 0 {
+		resolveCache = url.NewResolveCache(cacheTtl, cacheSize)
+	}
+
+	defaultRedirectType, err := strconv.Atoi(getEnv("DEFAULT_REDIRECT_TYPE", "302"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := validation.Validate(defaultRedirectType, validation.In(url.RedirectTypes...)); err != nil {
+		log.Fatal("DEFAULT_REDIRECT_TYPE: ", err)
+	}
+
+	// PURGE_RETENTION=0 disables purge job, purge endpoint then requires retention query
+	var purger *url.Purger
+	purgeRetention, err := time.ParseDuration(getEnv("PURGE_RETENTION", "0"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	purgeInterval, err := time.ParseDuration(getEnv("PURGE_INTERVAL", "1h"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if purgeRetention > 0 {
+		purger = url.NewPurger(repo, purgeRetention, purgeInterval)
+		purger.Start()
+	}
+
+	// SWEEP_INTERVAL=0 disables expiry sweeper
+	var sweeper *url.Sweeper
+	sweepInterval, err := time.ParseDuration(getEnv("SWEEP_INTERVAL", "1m"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sweepInterval > 0 {
+		sweeper = url.NewSweeper(repo, sweepInterval)
+		sweeper.Start()
+	}
+
+	// BLOCKLIST_FILE adds rules of file to rules in database, BLOCKLIST_RELOAD_INTERVAL=0 disables periodic reload
+	blockListInterval, err := time.ParseDuration(getEnv("BLOCKLIST_RELOAD_INTERVAL", "1m"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	blockList := url.NewBlockList(repo, os.Getenv("BLOCKLIST_FILE"), blockListInterval)
+	if err := blockList.Reload(); err != nil {
+		log.Fatal(err)
+	}
+	if blockListInterval > 0 {
+		blockList.Start()
+	}
+
+	// THREAT_FEED_DIR enables screening against feed files of directory, THREAT_SCAN_INTERVAL=0 disables rescan
+	// of existing urls, THREAT_ACTION=disable disables flagged urls in addition to flagging them
+	var threatFeeds *url.ThreatFeeds
+	var threatScanner *url.ThreatScanner
+	threatScanInterval, err := time.ParseDuration(getEnv("THREAT_SCAN_INTERVAL", "1h"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	threatAction := getEnv("THREAT_ACTION", "flag")
+	if err := validation.Validate(threatAction, validation.In("flag", "disable")); err != nil {
+		log.Fatal("THREAT_ACTION: ", err)
+	}
+	if dir := os.Getenv("THREAT_FEED_DIR"); dir != "" {
+		threatFeeds = url.NewThreatFeeds(dir)
+		if err := threatFeeds.Reload(); err != nil {
+			log.Fatal(err)
+		}
+		if threatScanInterval > 0 {
+			threatScanner = url.NewThreatScanner(repo, threatFeeds, resolveCache, threatAction == "disable", threatScanInterval)
+			threatScanner.Start()
+		}
+	}
+
+	// SHORTENER_POLICY=resolve stores final destination of urls to this service or other shorteners instead of rejecting them
+	shortenerPolicy := getEnv("SHORTENER_POLICY", url.PolicyReject)
+	if err := validation.Validate(shortenerPolicy, validation.In(url.ShortenerPolicies...)); err != nil {
+		log.Fatal("SHORTENER_POLICY: ", err)
+	}
+	var shortenerDomains []string
+	if domains := os.Getenv("SHORTENER_DOMAINS"); domains != "" {
+		shortenerDomains = strings.Split(domains, ",")
+	}
+	var ownHosts []string
+	if hosts := os.Getenv("OWN_HOSTS"); hosts != "" {
+		ownHosts = strings.Split(hosts, ",")
+	}
+
+	// UNLOCK_ATTEMPTS wrong passwords of protected link within UNLOCK_LOCKOUT lock it for UNLOCK_LOCKOUT
+	unlockAttempts, err := strconv.Atoi(getEnv("UNLOCK_ATTEMPTS", "5"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	unlockLockout, err := time.ParseDuration(getEnv("UNLOCK_LOCKOUT", "15m"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// MIN_EXPIRY and MAX_EXPIRY limit expiry of created and updated links from now
+	minExpiry, err := time.ParseDuration(getEnv("MIN_EXPIRY", "1m"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	maxExpiry, err := time.ParseDuration(getEnv("MAX_EXPIRY", "87600h"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// TRUSTED_PROXIES reverse proxies in front of the app which append client ip to PROXY_HEADER
+	trustedProxies, err := strconv.Atoi(getEnv("TRUSTED_PROXIES", "1"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// RATE_LIMIT_CREATE and RATE_LIMIT_REDIRECT are token buckets of requests/period per client, 0 disables limit
+	authLimit, err := ratelimit.ParseLimit(getEnv("RATE_LIMIT_AUTH", "60/1m"))
+	if err != nil {
+		log.Fatal("RATE_LIMIT_AUTH: ", err)
+	}
+	createLimit, err := ratelimit.ParseLimit(getEnv("RATE_LIMIT_CREATE", "30/1m"))
+	if err != nil {
+		log.Fatal("RATE_LIMIT_CREATE: ", err)
+	}
+	redirectLimit, err := ratelimit.ParseLimit(getEnv("RATE_LIMIT_REDIRECT", "600/1m"))
+	if err != nil {
+		log.Fatal("RATE_LIMIT_REDIRECT: ", err)
+	}
+	rateLimitSize, err := strconv.Atoi(getEnv("RATE_LIMIT_SIZE", "100000"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	app := Setup(repo, authService, Options{
+		// REQUIRE_API_KEY=true rejects creating url without X-API-Key header
+		RequireApiKey:  os.Getenv("REQUIRE_API_KEY") == "true",
+		ProxyHeader:    os.Getenv("PROXY_HEADER"),
+		TrustedProxies: trustedProxies,
+		RateLimitStore: ratelimit.NewMemoryStore(rateLimitSize),
+		AuthLimit:      authLimit,
+		CreateLimit:    createLimit,
+		RedirectLimit:  redirectLimit,
+	}, url.Config{
+		CodeGenerator:       codeGenerator,
+		ReuseExisting:       os.Getenv("REUSE_EXISTING") == "true",
+		IpHashSalt:          ipHashSalt,
+		HitCounter:          hitCounter,
+		ErrorPage:           errorPage,
+		Cache:               resolveCache,
+		DefaultRedirectType: defaultRedirectType,
+		PurgeRetention:      purgeRetention,
+		Sweeper:             sweeper,
+		BlockList:           blockList,
+		ThreatFeeds:         threatFeeds,
+		OwnHosts:            ownHosts,
+		ShortenerDomains:    shortenerDomains,
+		ShortenerPolicy:     shortenerPolicy,
+		UnlockAttempts:      unlockAttempts,
+		UnlockLockout:       unlockLockout,
+		MinExpiry:           minExpiry,
+		MaxExpiry:           maxExpiry,
+	})
+
+	ln, err := net.Listen("tcp", ":3000")
+	if err != nil {
+		log.Panic(err)
+	}
+	err = serve(app, ln, func() {
+		if purger != nil {
+			purger.Stop()
+		}
+		if sweeper != nil {
+			sweeper.Stop()
+		}
+		if blockListInterval > 0 {
+			blockList.Stop()
+		}
+		if threatScanner != nil {
+			threatScanner.Stop()
+		}
+		if hitCounter != nil {
+			if err := hitCounter.Stop(); err != nil {
+				log.Println(err)
+			}
+		}
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if dbClient != nil {
+		if err := db.Close(dbClient); err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+// shutdownSignals stop server gracefully, SIGTERM is sent by docker and orchestrators
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// serve run app on ln until one of shutdownSignals, then cleanup stops background workers so that
+// buffered hits and clicks are flushed
+func serve(app *fiber.App, ln net.Listener, cleanup func()) error {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, shutdownSignals...)
+	defer signal.Stop(c)
 	go func() {
 		_ = <-c
 		fmt.Println("Gracefully shutting down...")
 		_ = app.Shutdown()
 	}()
 
-	if err := app.Listen(":3000"); err != nil {
-		log.Panic(err)
-	}
+	err := app.Listener(ln)
 
 	fmt.Println("Running cleanup tasks...")
-	if err := db.Close(dbClient); err != nil {
-		log.Panic(err)
-	}
+	cleanup()
+	return err
 }
 
-func Setup(dbClient *gorm.DB) *fiber.App {
-	app := fiber.New()
+// Options of http server which are not part of url service
+type Options struct {
+	RequireApiKey bool
+	// ProxyHeader header of client ip set by reverse proxy, e.g. X-Forwarded-For
+	ProxyHeader string
+	// TrustedProxies number of reverse proxies which append to ProxyHeader, client ip is the hop added by the
+	// outermost one, hops on the left of it are sent by client, default is 1
+	TrustedProxies int
+	RateLimitStore ratelimit.Store
+	// AuthLimit budget of requests with credentials per client ip, taken before credentials are checked
+	AuthLimit     ratelimit.Limit
+	CreateLimit   ratelimit.Limit
+	RedirectLimit ratelimit.Limit
+}
+
+func Setup(repo url.Repository, authService auth.Service, options Options, config url.Config) *fiber.App {
+	app := fiber.New(fiber.Config{
+		ProxyHeader: options.ProxyHeader,
+	})
+	if options.ProxyHeader != "" {
+		// c.IP() returns raw ProxyHeader so it is replaced by trusted hop before other handlers read it
+		app.Use(trustedProxyHeader(options.ProxyHeader, options.TrustedProxies))
+	}
 
-	urlService := url.New(dbClient)
+	urlService := url.New(repo, config)
 
 	app.Use(logger.New())
-	app.Use(cache.New(cache.Config{
-		Expiration: 30 * time.Minute,
-	}))
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("Hello, World!")
 	})
 
-	app.Get("/:code", urlService.Redirect)
-	app.Post("/", urlService.Create)
-
-	// group route for admin auth
-	admin := app.Group("/admin", basicauth.New(basicauth.Config{
-		Users: map[string]string{
-			"admin": "demo",
+	// budget of requests with credentials is keyed by client ip so that wrong credentials are limited too
+	authLimiter := ratelimit.New(ratelimit.Config{
+		Name:  "auth",
+		Store: options.RateLimitStore,
+		Limit: options.AuthLimit,
+		Key: func(c *fiber.Ctx) string {
+			return "ip:" + c.IP()
 		},
+		Next: func(c *fiber.Ctx) bool {
+			return c.Get(auth.HeaderApiKey) == "" && c.Get(fiber.HeaderAuthorization) == ""
+		},
+	})
+	// separate budgets of create and redirect, keyed by api key or client ip
+	createLimiter := ratelimit.New(ratelimit.Config{
+		Name:  "create",
+		Store: options.RateLimitStore,
+		Limit: options.CreateLimit,
+		Key:   rateLimitKey,
+	})
+	redirectLimiter := ratelimit.New(ratelimit.Config{
+		Name:  "redirect",
+		Store: options.RateLimitStore,
+		Limit: options.RedirectLimit,
+		Key:   rateLimitKey,
+	})
+
+	app.Get("/:code", authLimiter, auth.ApiKey(authService, false), redirectLimiter, urlService.Redirect)
+	// unlock form of password protected link
+	app.Post("/:code", redirectLimiter, urlService.Redirect)
+	app.Post("/", authLimiter, auth.ApiKey(authService, options.RequireApiKey), auth.Account(authService), createLimiter, urlService.Create)
+
+	// group route for links of the caller identified by api key or account
+	me := app.Group("/me", authLimiter, auth.ApiKey(authService, false), auth.Account(authService), auth.RequireOwner)
+	me.Get("/urls", urlService.MyUrls)
+	me.Delete("/urls/:code", urlService.DeleteOwn)
+
+	// group route for admin auth, each route requires minimum role of admin user
+	admin := app.Group("/admin", authLimiter, basicauth.New(basicauth.Config{
+		Authorizer: authService.Authorize,
 	}))
-	admin.Get("/urls/:code?", urlService.List)
-	admin.Delete("/urls/:code", urlService.SoftDelete)
+	viewer := authService.RequireRole(auth.RoleViewer)
+	editor := authService.RequireRole(auth.RoleEditor)
+	owner := authService.RequireRole(auth.RoleOwner)
+
+	admin.Get("/urls/:code?", viewer, urlService.List)
+	admin.Delete("/urls/:code", editor, urlService.SoftDelete)
+	admin.Get("/urls/:code/stats", viewer, urlService.Stats)
+	admin.Patch("/urls/:code", editor, urlService.Update)
+	admin.Get("/urls/:code/history", viewer, urlService.History)
+	admin.Post("/urls/:code/restore", editor, urlService.Restore)
+	admin.Post("/purge", owner, urlService.Purge)
+	admin.Get("/sweeper", viewer, urlService.SweeperStatus)
+	admin.Get("/blocklist", viewer, urlService.BlockRules)
+	admin.Post("/blocklist", editor, urlService.AddBlockRule)
+	admin.Delete("/blocklist/:id", editor, urlService.DeleteBlockRule)
+	admin.Post("/blocklist/reload", editor, urlService.ReloadBlockList)
+	admin.Get("/threats", viewer, urlService.ThreatReport)
+	admin.Get("/api-keys", owner, authService.ListApiKeys)
+	admin.Post("/api-keys", owner, authService.Issue)
+	admin.Delete("/api-keys/:id", owner, authService.Revoke)
 
 	return app
 }
+
+// rateLimitKey return api key of client verified by auth.ApiKey or its ip
+func rateLimitKey(c *fiber.Ctx) string {
+	if apiKey, ok := auth.ApiKeyFromContext(c); ok {
+		return "api_key:" + strconv.FormatUint(uint64(apiKey.ID), 10)
+	}
+	return "ip:" + c.IP()
+}
+
+// trustedProxyHeader replace header of client ip by the hop added by outermost of trusted proxies, remote ip is used
+// when header does not have such hop so that client can not choose ip of rate limit and click events
+func trustedProxyHeader(header string, proxies int) fiber.Handler {
+	if proxies <= 0 {
+		proxies = 1
+	}
+	return func(c *fiber.Ctx) error {
+		ip := c.Context().RemoteIP().String()
+		hops := strings.Split(c.Get(header), ",")
+		if index := len(hops) - proxies; index >= 0 {
+			if hop := strings.TrimSpace(hops[index]); net.ParseIP(hop) != nil {
+				ip = hop
+			}
+		}
+		c.Request().Header.Set(header, ip)
+		return c.Next()
+	}
+}
+
+// getEnv return environment variable or fallback when it is not set
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
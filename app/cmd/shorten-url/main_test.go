@@ -0,0 +1,251 @@
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"rabbit-shorten-url/internal/auth"
+	"rabbit-shorten-url/internal/ratelimit"
+	"rabbit-shorten-url/internal/url"
+	"rabbit-shorten-url/internal/url/models"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSetup_AdminRoles(t *testing.T) {
+	authService := auth.New(auth.NewMemoryRepository())
+	for _, role := range []string{auth.RoleViewer, auth.RoleEditor, auth.RoleOwner} {
+		if err := authService.SetPassword(role, "password"); err != nil {
+			t.Fatalf("SetPassword() error = %v", err)
+		}
+		if err := authService.SetRole(role, role); err != nil {
+			t.Fatalf("SetRole() error = %v", err)
+		}
+	}
+	app := Setup(url.NewMemoryRepository(), authService, Options{}, url.Config{})
+
+	routes := []struct {
+		method string
+		path   string
+		body   string
+		role   string
+	}{
+		{"GET", "/admin/urls", "", auth.RoleViewer},
+		{"GET", "/admin/urls/abc", "", auth.RoleViewer},
+		{"GET", "/admin/urls/abc/stats", "", auth.RoleViewer},
+		{"GET", "/admin/urls/abc/history", "", auth.RoleViewer},
+		{"GET", "/admin/sweeper", "", auth.RoleViewer},
+		{"GET", "/admin/blocklist", "", auth.RoleViewer},
+		{"POST", "/admin/blocklist", `{"kind": "domain", "pattern": "bad.example"}`, auth.RoleEditor},
+		{"DELETE", "/admin/blocklist/1", "", auth.RoleEditor},
+		{"POST", "/admin/blocklist/reload", "", auth.RoleEditor},
+		{"GET", "/admin/threats", "", auth.RoleViewer},
+		{"PATCH", "/admin/urls/abc", `{"url": "https://docs.gofiber.io/"}`, auth.RoleEditor},
+		{"DELETE", "/admin/urls/abc", "", auth.RoleEditor},
+		{"POST", "/admin/urls/abc/restore", "", auth.RoleEditor},
+		{"POST", "/admin/purge?retention=1h", "", auth.RoleOwner},
+		{"GET", "/admin/api-keys", "", auth.RoleOwner},
+		{"POST", "/admin/api-keys", `{"name": "ci"}`, auth.RoleOwner},
+		{"DELETE", "/admin/api-keys/1", "", auth.RoleOwner},
+	}
+	levels := map[string]int{auth.RoleViewer: 1, auth.RoleEditor: 2, auth.RoleOwner: 3}
+
+	for _, route := range routes {
+		for username, level := range levels {
+			allowed := level >= levels[route.role]
+			t.Run(route.method+" "+route.path+" as "+username, func(t *testing.T) {
+				req := httptest.NewRequest(route.method, route.path, strings.NewReader(route.body))
+				req.Header.Add("Content-Type", "application/json")
+				req.SetBasicAuth(username, "password")
+				res, _ := app.Test(req, -1)
+
+				if allowed && (res.StatusCode == fiber.StatusForbidden || res.StatusCode == fiber.StatusUnauthorized) {
+					t.Errorf("status = %v, want allowed", res.StatusCode)
+				}
+				if !allowed && res.StatusCode != fiber.StatusForbidden {
+					t.Errorf("status = %v, want %v", res.StatusCode, fiber.StatusForbidden)
+				}
+			})
+		}
+	}
+}
+
+func TestSetup_AdminIsNotAuthenticated(t *testing.T) {
+	app := Setup(url.NewMemoryRepository(), auth.New(auth.NewMemoryRepository()), Options{}, url.Config{})
+
+	req := httptest.NewRequest("GET", "/admin/urls", nil)
+	req.SetBasicAuth("unknown", "password")
+	res, _ := app.Test(req, -1)
+
+	if res.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("status = %v, want %v", res.StatusCode, fiber.StatusUnauthorized)
+	}
+}
+
+func TestSetup_RateLimit(t *testing.T) {
+	authService := auth.New(auth.NewMemoryRepository())
+	key, _, err := authService.IssueApiKey("ci")
+	if err != nil {
+		t.Fatalf("IssueApiKey() error = %v", err)
+	}
+	app := Setup(url.NewMemoryRepository(), authService, Options{
+		RateLimitStore: ratelimit.NewMemoryStore(100),
+		CreateLimit:    ratelimit.Limit{Requests: 1, Period: time.Minute},
+		RedirectLimit:  ratelimit.Limit{Requests: 1, Period: time.Minute},
+	}, url.Config{})
+
+	send := func(method, path, key string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"url": "https://docs.gofiber.io/"}`))
+		req.Header.Add("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Add(auth.HeaderApiKey, key)
+		}
+		res, _ := app.Test(req, -1)
+		return res.StatusCode
+	}
+
+	if status := send("POST", "/", ""); status != fiber.StatusCreated {
+		t.Errorf("create status = %v, want %v", status, fiber.StatusCreated)
+	}
+	if status := send("POST", "/", ""); status != fiber.StatusTooManyRequests {
+		t.Errorf("create status = %v, want %v", status, fiber.StatusTooManyRequests)
+	}
+	// api key has its own budget
+	if status := send("POST", "/", key); status != fiber.StatusCreated {
+		t.Errorf("create with api key status = %v, want %v", status, fiber.StatusCreated)
+	}
+	// redirect has separate budget
+	if status := send("GET", "/unknown", ""); status != fiber.StatusNotFound {
+		t.Errorf("redirect status = %v, want %v", status, fiber.StatusNotFound)
+	}
+	if status := send("GET", "/unknown", ""); status != fiber.StatusTooManyRequests {
+		t.Errorf("redirect status = %v, want %v", status, fiber.StatusTooManyRequests)
+	}
+}
+
+func TestSetup_AuthRateLimit(t *testing.T) {
+	authService := auth.New(auth.NewMemoryRepository())
+	if err := authService.SetPassword("admin", "password"); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	app := Setup(url.NewMemoryRepository(), authService, Options{
+		RateLimitStore: ratelimit.NewMemoryStore(100),
+		AuthLimit:      ratelimit.Limit{Requests: 2, Period: time.Minute},
+		CreateLimit:    ratelimit.Limit{Requests: 1, Period: time.Minute},
+	}, url.Config{})
+
+	send := func(method, path string, header map[string]string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"url": "https://docs.gofiber.io/"}`))
+		req.Header.Add("Content-Type", "application/json")
+		for key, value := range header {
+			req.Header.Add(key, value)
+		}
+		res, _ := app.Test(req, -1)
+		return res.StatusCode
+	}
+	wrongPassword := map[string]string{fiber.HeaderAuthorization: "Basic YWRtaW46d3Jvbmc="}
+
+	// wrong credentials are limited before they are checked
+	if status := send("POST", "/", wrongPassword); status != fiber.StatusUnauthorized {
+		t.Errorf("create status = %v, want %v", status, fiber.StatusUnauthorized)
+	}
+	if status := send("GET", "/admin/urls", wrongPassword); status != fiber.StatusUnauthorized {
+		t.Errorf("admin status = %v, want %v", status, fiber.StatusUnauthorized)
+	}
+	if status := send("POST", "/", wrongPassword); status != fiber.StatusTooManyRequests {
+		t.Errorf("create status = %v, want %v", status, fiber.StatusTooManyRequests)
+	}
+	if status := send("GET", "/me/urls", map[string]string{auth.HeaderApiKey: "bogus"}); status != fiber.StatusTooManyRequests {
+		t.Errorf("me status = %v, want %v", status, fiber.StatusTooManyRequests)
+	}
+	// request without credentials only takes create budget
+	if status := send("POST", "/", nil); status != fiber.StatusCreated {
+		t.Errorf("create status = %v, want %v", status, fiber.StatusCreated)
+	}
+}
+
+func TestSetup_ProxyHeader(t *testing.T) {
+	app := Setup(url.NewMemoryRepository(), auth.New(auth.NewMemoryRepository()), Options{
+		ProxyHeader:    fiber.HeaderXForwardedFor,
+		TrustedProxies: 1,
+		RateLimitStore: ratelimit.NewMemoryStore(100),
+		CreateLimit:    ratelimit.Limit{Requests: 1, Period: time.Minute},
+	}, url.Config{})
+
+	send := func(forwardedFor string) int {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/"}`))
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add(fiber.HeaderXForwardedFor, forwardedFor)
+		res, _ := app.Test(req, -1)
+		return res.StatusCode
+	}
+
+	if status := send("1.1.1.1, 10.0.0.1"); status != fiber.StatusCreated {
+		t.Errorf("create status = %v, want %v", status, fiber.StatusCreated)
+	}
+	// hop sent by client does not change budget of ip added by proxy
+	if status := send("2.2.2.2, 10.0.0.1"); status != fiber.StatusTooManyRequests {
+		t.Errorf("create with spoofed hop status = %v, want %v", status, fiber.StatusTooManyRequests)
+	}
+	if status := send("10.0.0.2"); status != fiber.StatusCreated {
+		t.Errorf("create of another client status = %v, want %v", status, fiber.StatusCreated)
+	}
+}
+
+func TestServe_Sigterm(t *testing.T) {
+	repo := url.NewMemoryRepository()
+	if err := repo.Insert(&models.Url{ShortCode: "test1234", FullUrl: "https://www.google.com"}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	hitCounter := url.NewHitCounter(repo, time.Hour)
+	hitCounter.Start()
+	hitCounter.Add("test1234", 3)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(app, ln, func() {
+			if err := hitCounter.Stop(); err != nil {
+				t.Errorf("Stop() error = %v", err)
+			}
+		})
+	}()
+
+	// signal is sent once server answers so that shutdown does not run before it listens
+	for i := 0; ; i++ {
+		if res, err := http.Get("http://" + ln.Addr().String() + "/"); err == nil {
+			_ = res.Body.Close()
+			break
+		}
+		if i == 100 {
+			t.Fatal("server does not answer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("Kill() error = %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve() does not return on SIGTERM")
+	}
+	if stored, _ := repo.GetByCode("test1234"); stored.Hits != 3 {
+		t.Errorf("hits = %v, want buffered hits flushed on shutdown", stored.Hits)
+	}
+}
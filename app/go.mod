@@ -6,10 +6,12 @@ require (
 	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/andybalholm/brotli v1.0.1 // indirect
 	github.com/go-ozzo/ozzo-validation/v4 v4.3.0
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/gofiber/fiber/v2 v2.5.0
 	github.com/klauspost/compress v1.11.7 // indirect
 	github.com/stretchr/testify v1.4.0
 	github.com/valyala/fasthttp v1.21.0 // indirect
+	golang.org/x/crypto v0.0.0-20210220033148-5ea612d1eb83
 	golang.org/x/sys v0.0.0-20210223212115-eede4237b368 // indirect
 	gorm.io/driver/mysql v1.0.4
 	gorm.io/gorm v1.20.12
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gofiber/fiber/v2"
+	"rabbit-shorten-url/internal/auth/models"
+	"strconv"
+	"time"
+)
+
+const (
+	// HeaderApiKey header of api key sent by callers
+	HeaderApiKey = "X-API-Key"
+	// localsApiKey key of verified api key in fiber locals
+	localsApiKey = "api_key"
+
+	apiKeyPrefix      = "rk_"
+	apiKeyBytes       = 32
+	apiKeyShownLength = 8
+	maxApiKeyName     = 100
+)
+
+var (
+	ErrApiKeyRequired = errors.New("api key is required")
+	ErrApiKeyInvalid  = errors.New("api key is invalid or revoked")
+)
+
+// IssueRequest handle incoming post request to issue api key with name of its owner
+type IssueRequest struct {
+	Name string `json:"name"`
+}
+
+// IssueResponse return plain api key, it is shown only once
+type IssueResponse struct {
+	Key    string        `json:"key"`
+	ApiKey models.ApiKey `json:"api_key"`
+}
+
+// ErrResponse return error response with message
+type ErrResponse struct {
+	Error string `json:"error"`
+}
+
+// SuccessResponse return response with message
+type SuccessResponse struct {
+	Message string `json:"message"`
+}
+
+// hashApiKey return sha256 hex of api key, keys are random so a fast hash is enough
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueApiKey create new random api key, only its hash is stored
+func (s *service) IssueApiKey(name string) (string, models.ApiKey, error) {
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", models.ApiKey{}, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(b)
+
+	apiKey := models.ApiKey{
+		Name:    name,
+		Prefix:  key[:len(apiKeyPrefix)+apiKeyShownLength],
+		KeyHash: hashApiKey(key),
+	}
+	if err := s.repo.InsertApiKey(&apiKey); err != nil {
+		return "", apiKey, err
+	}
+	return key, apiKey, nil
+}
+
+// VerifyApiKey return api key which is not revoked or ErrApiKeyInvalid
+func (s *service) VerifyApiKey(key string) (models.ApiKey, error) {
+	apiKey, err := s.repo.GetApiKeyByHash(hashApiKey(key))
+	if errors.Is(err, ErrNotFound) || (err == nil && apiKey.RevokedAt != nil) {
+		return apiKey, ErrApiKeyInvalid
+	}
+	return apiKey, err
+}
+
+// ApiKey is middleware to verify api key in HeaderApiKey, request without key is rejected when required
+func ApiKey(s Service, required bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderApiKey)
+		if key == "" {
+			if required {
+				return c.Status(fiber.StatusUnauthorized).JSON(ErrResponse{ErrApiKeyRequired.Error()})
+			}
+			return c.Next()
+		}
+
+		apiKey, err := s.VerifyApiKey(key)
+		if errors.Is(err, ErrApiKeyInvalid) {
+			return c.Status(fiber.StatusUnauthorized).JSON(ErrResponse{err.Error()})
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+		}
+
+		c.Locals(localsApiKey, apiKey)
+		return c.Next()
+	}
+}
+
+// ApiKeyFromContext return api key verified by ApiKey middleware
+func ApiKeyFromContext(c *fiber.Ctx) (models.ApiKey, bool) {
+	apiKey, ok := c.Locals(localsApiKey).(models.ApiKey)
+	return apiKey, ok
+}
+
+// Issue is used to issue api key from request
+func (s *service) Issue(c *fiber.Ctx) error {
+	req := new(IssueRequest)
+
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+	}
+
+	if err := validation.Validate(req.Name,
+		validation.Required,                     // not empty
+		validation.RuneLength(1, maxApiKeyName), // length of name
+	); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{"name: " + err.Error()})
+	}
+
+	key, apiKey, err := s.IssueApiKey(req.Name)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(IssueResponse{key, apiKey})
+}
+
+// ListApiKeys is used to list issued api keys without their secret
+func (s *service) ListApiKeys(c *fiber.Ctx) error {
+	apiKeys, err := s.repo.ListApiKeys()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+	return c.JSON(apiKeys)
+}
+
+// Revoke is used to revoke api key by id
+func (s *service) Revoke(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	}
+
+	err = s.repo.RevokeApiKey(uint(id), time.Now())
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	return c.JSON(SuccessResponse{"api key " + c.Params("id") + " has been revoked"})
+}
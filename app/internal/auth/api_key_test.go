@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestService_ApiKey(t *testing.T) {
+	s := New(NewMemoryRepository())
+
+	key, apiKey, err := s.IssueApiKey("ci")
+	if err != nil {
+		t.Fatalf("IssueApiKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, apiKey.Prefix) || apiKey.KeyHash == key {
+		t.Errorf("IssueApiKey() key = %v, api key = %+v", key, apiKey)
+	}
+
+	if _, err := s.VerifyApiKey(key); err != nil {
+		t.Errorf("VerifyApiKey() error = %v", err)
+	}
+	if _, err := s.VerifyApiKey(key + "x"); err != ErrApiKeyInvalid {
+		t.Errorf("VerifyApiKey() with wrong key error = %v, want %v", err, ErrApiKeyInvalid)
+	}
+
+	app := fiber.New()
+	app.Delete("/api-keys/:id", s.Revoke)
+
+	res, _ := app.Test(httptest.NewRequest("DELETE", "/api-keys/1", nil), -1)
+	if res.StatusCode != fiber.StatusOK {
+		t.Errorf("Revoke() status = %v, want %v", res.StatusCode, fiber.StatusOK)
+	}
+	res, _ = app.Test(httptest.NewRequest("DELETE", "/api-keys/1", nil), -1)
+	if res.StatusCode != fiber.StatusNotFound {
+		t.Errorf("Revoke() revoked key status = %v, want %v", res.StatusCode, fiber.StatusNotFound)
+	}
+
+	if _, err := s.VerifyApiKey(key); err != ErrApiKeyInvalid {
+		t.Errorf("VerifyApiKey() with revoked key error = %v, want %v", err, ErrApiKeyInvalid)
+	}
+}
+
+func TestService_Issue(t *testing.T) {
+	s := New(NewMemoryRepository())
+	app := fiber.New()
+	app.Post("/api-keys", s.Issue)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"should return error on empty name", `{"name": ""}`, fiber.StatusBadRequest},
+		{"should issue api key", `{"name": "ci"}`, fiber.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api-keys", strings.NewReader(tt.body))
+			req.Header.Add("Content-Type", "application/json")
+			res, _ := app.Test(req, -1)
+			if res.StatusCode != tt.want {
+				t.Errorf("Issue() status = %v, want %v", res.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwner(t *testing.T) {
+	s := New(NewMemoryRepository())
+	if err := s.SetPassword("alice", "password"); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	key, _, err := s.IssueApiKey("ci")
+	if err != nil {
+		t.Fatalf("IssueApiKey() error = %v", err)
+	}
+
+	app := fiber.New()
+	app.Get("/", ApiKey(s, false), Account(s), func(c *fiber.Ctx) error {
+		return c.SendString(Owner(c))
+	})
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		key      string
+		status   int
+		want     string
+	}{
+		{"should be anonymous", "", "", "", fiber.StatusOK, ""},
+		{"should be account", "alice", "password", "", fiber.StatusOK, "user:alice"},
+		{"should reject wrong password", "alice", "wrong-password", "", fiber.StatusUnauthorized, ""},
+		{"should be api key", "", "", key, fiber.StatusOK, "api_key:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.username != "" {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			if tt.key != "" {
+				req.Header.Add(HeaderApiKey, tt.key)
+			}
+			res, _ := app.Test(req, -1)
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.status {
+				t.Errorf("status = %v, want %v", res.StatusCode, tt.status)
+			}
+			if res.StatusCode == fiber.StatusOK && string(body) != tt.want {
+				t.Errorf("Owner() = %v, want %v", string(body), tt.want)
+			}
+		})
+	}
+}
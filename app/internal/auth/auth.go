@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"errors"
+	"github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"rabbit-shorten-url/internal/auth/models"
+)
+
+const (
+	minPasswordLength = 8
+	// maxPasswordLength bcrypt ignores bytes after 72
+	maxPasswordLength = 72
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+
+	// dummyHash is compared when user does not exist so response time does not reveal usernames
+	dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+)
+
+// Service interface for auth package
+type Service interface {
+	Authorize(username, password string) bool
+	SetPassword(username, password string) error
+	EnsureUser(username, password string) error
+	SetRole(username, role string) error
+	RequireRole(role string) fiber.Handler
+	VerifyApiKey(key string) (models.ApiKey, error)
+	Issue(c *fiber.Ctx) error
+	ListApiKeys(c *fiber.Ctx) error
+	Revoke(c *fiber.Ctx) error
+}
+
+type service struct {
+	repo Repository
+}
+
+// New initial auth service with admin user repository
+func New(repo Repository) *service {
+	return &service{
+		repo: repo,
+	}
+}
+
+// Authorize check username and password against bcrypt hash in store, used as basicauth.Config.Authorizer
+func (s *service) Authorize(username, password string) bool {
+	user, err := s.repo.GetUser(username)
+	if err != nil {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+// SetPassword create admin user with viewer role or rotate password of existing one
+func (s *service) SetPassword(username, password string) error {
+	role := RoleViewer
+	user, err := s.repo.GetUser(username)
+	if err == nil {
+		role = user.Role
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return s.saveUser(username, password, role)
+}
+
+// saveUser validate and store admin user with bcrypt hash of password
+func (s *service) saveUser(username, password, role string) error {
+	if err := validation.Validate(username, validation.Required); err != nil {
+		return errors.New("username: " + err.Error())
+	}
+	if err := validation.Validate(password,
+		validation.Required,
+		validation.Length(minPasswordLength, maxPasswordLength),
+	); err != nil {
+		return errors.New("password: " + err.Error())
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.repo.SaveUser(&models.AdminUser{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+	})
+}
+
+// EnsureUser create admin user with owner role when it does not exist, existing password is kept
+func (s *service) EnsureUser(username, password string) error {
+	_, err := s.repo.GetUser(username)
+	if err == nil {
+		return nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return s.saveUser(username, password, RoleOwner)
+}
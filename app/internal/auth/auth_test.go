@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"testing"
+)
+
+func TestService_SetPassword(t *testing.T) {
+	s := New(NewMemoryRepository())
+
+	type args struct {
+		username string
+		password string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			"should return error on empty username",
+			args{username: "", password: "password"},
+			true,
+		},
+		{
+			"should return error on short password",
+			args{username: "admin", password: "demo"},
+			true,
+		},
+		{
+			"should not return error",
+			args{username: "admin", password: "password"},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.SetPassword(tt.args.username, tt.args.password); (err != nil) != tt.wantErr {
+				t.Errorf("SetPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestService_Authorize(t *testing.T) {
+	repo := NewMemoryRepository()
+	s := New(repo)
+	if err := s.SetPassword("admin", "password"); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+
+	if !s.Authorize("admin", "password") {
+		t.Errorf("Authorize() = false, want true")
+	}
+	if s.Authorize("admin", "wrong-password") {
+		t.Errorf("Authorize() with wrong password = true, want false")
+	}
+	if s.Authorize("unknown", "password") {
+		t.Errorf("Authorize() with unknown user = true, want false")
+	}
+
+	// rotate password
+	if err := s.SetPassword("admin", "new-password"); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	if s.Authorize("admin", "password") {
+		t.Errorf("Authorize() with old password = true, want false")
+	}
+	if !s.Authorize("admin", "new-password") {
+		t.Errorf("Authorize() with new password = false, want true")
+	}
+
+	// ensure keeps existing password
+	if err := s.EnsureUser("admin", "other-password"); err != nil {
+		t.Fatalf("EnsureUser() error = %v", err)
+	}
+	if !s.Authorize("admin", "new-password") {
+		t.Errorf("Authorize() after EnsureUser() = false, want true")
+	}
+}
+
+func TestService_SetRole(t *testing.T) {
+	s := New(NewMemoryRepository())
+
+	if err := s.SetRole("unknown", RoleEditor); err != ErrNotFound {
+		t.Errorf("SetRole() with unknown user error = %v, want %v", err, ErrNotFound)
+	}
+	if err := s.SetPassword("analyst", "password"); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	if err := s.SetRole("analyst", "admin"); err == nil {
+		t.Errorf("SetRole() with invalid role error = nil, want error")
+	}
+	if err := s.SetRole("analyst", RoleEditor); err != nil {
+		t.Errorf("SetRole() error = %v", err)
+	}
+
+	// rotating password keeps role
+	if err := s.SetPassword("analyst", "new-password"); err != nil {
+		t.Fatalf("SetPassword() error = %v", err)
+	}
+	user, _ := s.repo.GetUser("analyst")
+	if user.Role != RoleEditor {
+		t.Errorf("Role after SetPassword() = %v, want %v", user.Role, RoleEditor)
+	}
+
+	if err := s.EnsureUser("root", "password"); err != nil {
+		t.Fatalf("EnsureUser() error = %v", err)
+	}
+	user, _ = s.repo.GetUser("root")
+	if user.Role != RoleOwner {
+		t.Errorf("Role after EnsureUser() = %v, want %v", user.Role, RoleOwner)
+	}
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rabbit-shorten-url/internal/auth/models"
+	"time"
+)
+
+type gormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository initial admin user repository with dbClient
+func NewGormRepository(dbClient *gorm.DB) *gormRepository {
+	return &gormRepository{
+		db: dbClient,
+	}
+}
+
+func (r *gormRepository) GetUser(username string) (models.AdminUser, error) {
+	var user models.AdminUser
+	result := r.db.First(&user, "username", username)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return user, ErrNotFound
+	}
+	return user, result.Error
+}
+
+func (r *gormRepository) InsertApiKey(apiKey *models.ApiKey) error {
+	return r.db.Create(apiKey).Error
+}
+
+func (r *gormRepository) GetApiKeyByHash(hash string) (models.ApiKey, error) {
+	var apiKey models.ApiKey
+	result := r.db.First(&apiKey, "key_hash = ?", hash)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return apiKey, ErrNotFound
+	}
+	return apiKey, result.Error
+}
+
+func (r *gormRepository) ListApiKeys() ([]models.ApiKey, error) {
+	apiKeys := []models.ApiKey{}
+	result := r.db.Order("id").Find(&apiKeys)
+	return apiKeys, result.Error
+}
+
+func (r *gormRepository) RevokeApiKey(id uint, at time.Time) error {
+	result := r.db.Model(&models.ApiKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected <= 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormRepository) SaveUser(user *models.AdminUser) error {
+	return r.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"password_hash", "role", "updated_at"}),
+	}).Create(user).Error
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"rabbit-shorten-url/internal/auth/models"
+	"sync"
+	"time"
+)
+
+type memoryRepository struct {
+	mu      sync.RWMutex
+	users   map[string]models.AdminUser
+	apiKeys []models.ApiKey
+}
+
+// NewMemoryRepository initial in-memory admin user repository, used for tests and local development
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		users: map[string]models.AdminUser{},
+	}
+}
+
+func (r *memoryRepository) GetUser(username string) (models.AdminUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[username]
+	if !ok {
+		return models.AdminUser{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (r *memoryRepository) InsertApiKey(apiKey *models.ApiKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiKey.ID = uint(len(r.apiKeys) + 1)
+	apiKey.CreatedAt = time.Now()
+	r.apiKeys = append(r.apiKeys, *apiKey)
+	return nil
+}
+
+func (r *memoryRepository) GetApiKeyByHash(hash string) (models.ApiKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, apiKey := range r.apiKeys {
+		if apiKey.KeyHash == hash {
+			return apiKey, nil
+		}
+	}
+	return models.ApiKey{}, ErrNotFound
+}
+
+func (r *memoryRepository) ListApiKeys() ([]models.ApiKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.ApiKey{}, r.apiKeys...), nil
+}
+
+func (r *memoryRepository) RevokeApiKey(id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, apiKey := range r.apiKeys {
+		if apiKey.ID == id && apiKey.RevokedAt == nil {
+			r.apiKeys[i].RevokedAt = &at
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryRepository) SaveUser(user *models.AdminUser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := r.users[user.Username]; ok {
+		user.CreatedAt = existing.CreatedAt
+	} else {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	r.users[user.Username] = *user
+	return nil
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type AdminUser struct {
+	Username     string    `gorm:"primaryKey" json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
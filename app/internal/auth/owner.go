@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"strings"
+)
+
+var ErrUnauthenticated = errors.New("api key or account credentials are required")
+
+// Account is middleware to authenticate optional basic auth credentials of admin user,
+// request without credentials is passed anonymously and wrong credentials are rejected
+func Account(s Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		if header == "" {
+			return c.Next()
+		}
+
+		username, password, ok := parseBasicAuth(header)
+		if !ok || !s.Authorize(username, password) {
+			return c.Status(fiber.StatusUnauthorized).JSON(ErrResponse{fiber.ErrUnauthorized.Message})
+		}
+
+		c.Locals(localsUsername, username)
+		return c.Next()
+	}
+}
+
+// parseBasicAuth return username and password of basic Authorization header
+func parseBasicAuth(header string) (string, string, bool) {
+	if len(header) <= 6 || strings.ToLower(header[:6]) != "basic " {
+		return "", "", false
+	}
+	raw, err := base64.StdEncoding.DecodeString(header[6:])
+	if err != nil {
+		return "", "", false
+	}
+	credentials := string(raw)
+	index := strings.Index(credentials, ":")
+	if index < 0 {
+		return "", "", false
+	}
+	return credentials[:index], credentials[index+1:], true
+}
+
+// Owner return identity of caller authenticated by ApiKey or Account middleware,
+// "api_key:<id>" for api key, "user:<username>" for account or empty for anonymous
+func Owner(c *fiber.Ctx) string {
+	if apiKey, ok := ApiKeyFromContext(c); ok {
+		return "api_key:" + strconv.FormatUint(uint64(apiKey.ID), 10)
+	}
+	if username, ok := c.Locals(localsUsername).(string); ok && username != "" {
+		return "user:" + username
+	}
+	return ""
+}
+
+// RequireOwner is middleware to reject anonymous caller, used after ApiKey and Account
+func RequireOwner(c *fiber.Ctx) error {
+	if Owner(c) == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrResponse{ErrUnauthenticated.Error()})
+	}
+	return c.Next()
+}
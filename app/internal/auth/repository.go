@@ -0,0 +1,22 @@
+package auth
+
+import (
+	"rabbit-shorten-url/internal/auth/models"
+	"time"
+)
+
+// Repository interface for admin user storage, implemented by gorm (mysql) and in-memory backends
+type Repository interface {
+	// GetUser return admin user by username or ErrNotFound
+	GetUser(username string) (models.AdminUser, error)
+	// SaveUser create admin user or update it when username exists
+	SaveUser(user *models.AdminUser) error
+	// InsertApiKey store new api key
+	InsertApiKey(apiKey *models.ApiKey) error
+	// GetApiKeyByHash return api key by sha256 of key or ErrNotFound
+	GetApiKeyByHash(hash string) (models.ApiKey, error)
+	// ListApiKeys list all api keys
+	ListApiKeys() ([]models.ApiKey, error)
+	// RevokeApiKey set revoked_at of api key which is not revoked or return ErrNotFound
+	RevokeApiKey(id uint, at time.Time) error
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"errors"
+	"github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/gofiber/fiber/v2"
+)
+
+// roles of admin user, each role includes permissions of lower ones
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+// localsUsername key of username set by basicauth middleware
+const localsUsername = "username"
+
+var (
+	// Roles list of valid roles
+	Roles = []interface{}{RoleViewer, RoleEditor, RoleOwner}
+
+	ErrForbidden = errors.New("forbidden")
+
+	roleLevels = map[string]int{
+		RoleViewer: 1,
+		RoleEditor: 2,
+		RoleOwner:  3,
+	}
+)
+
+// SetRole change role of existing admin user
+func (s *service) SetRole(username, role string) error {
+	if err := validation.Validate(role, validation.Required, validation.In(Roles...)); err != nil {
+		return errors.New("role: " + err.Error())
+	}
+
+	user, err := s.repo.GetUser(username)
+	if err != nil {
+		return err
+	}
+	user.Role = role
+	return s.repo.SaveUser(&user)
+}
+
+// RequireRole is middleware to reject admin user whose role is lower than role, used after basicauth
+func (s *service) RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		username, _ := c.Locals(localsUsername).(string)
+
+		user, err := s.repo.GetUser(username)
+		if errors.Is(err, ErrNotFound) {
+			return c.Status(fiber.StatusForbidden).JSON(ErrResponse{ErrForbidden.Error()})
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+		}
+
+		if roleLevels[user.Role] < roleLevels[role] {
+			return c.Status(fiber.StatusForbidden).JSON(ErrResponse{ErrForbidden.Error()})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"container/list"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrStoreFull is the error in case of every tracked bucket is in use and new key can not be tracked
+var ErrStoreFull = errors.New("rate limit store is full")
+
+type bucket struct {
+	key       string
+	tokens    float64
+	updatedAt time.Time
+	limit     Limit
+}
+
+type memoryStore struct {
+	maxKeys int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	// order of buckets by last use, front is the most recently used
+	order *list.List
+}
+
+// NewMemoryStore initial in-memory store of token buckets with max number of tracked keys,
+// limits are per instance
+func NewMemoryStore(maxKeys int) *memoryStore {
+	return &memoryStore{
+		maxKeys: maxKeys,
+		buckets: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+func (s *memoryStore) Take(key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	capacity, rate := float64(limit.Requests), limit.rate()
+
+	element, ok := s.buckets[key]
+	if ok {
+		s.order.MoveToFront(element)
+	} else {
+		if len(s.buckets) >= s.maxKeys && !s.evict(now) {
+			return Result{}, ErrStoreFull
+		}
+		element = s.order.PushFront(&bucket{key, capacity, now, limit})
+		s.buckets[key] = element
+	}
+	b := element.Value.(*bucket)
+	if elapsed := now.Sub(b.updatedAt).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+		b.updatedAt = now
+	}
+
+	result := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsDuration((capacity - b.tokens) / rate)
+	return result, nil
+}
+
+// evict remove least recently used bucket when it is full again, it behaves the same as a new one,
+// buckets in use are never removed so that their limits are not reset
+func (s *memoryStore) evict(now time.Time) bool {
+	element := s.order.Back()
+	if element == nil {
+		return false
+	}
+	b := element.Value.(*bucket)
+	if b.tokens+now.Sub(b.updatedAt).Seconds()*b.limit.rate() < float64(b.limit.Requests) {
+		return false
+	}
+	s.order.Remove(element)
+	delete(s.buckets, b.key)
+	return true
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
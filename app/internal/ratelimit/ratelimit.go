@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrInvalidLimit    = errors.New("limit must be requests/period, e.g. 10/1m")
+)
+
+// Limit token bucket which holds Requests tokens and refills them evenly over Period
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parse limit of "requests/period" such as "10/1m", "0" disables limit
+func ParseLimit(value string) (Limit, error) {
+	if value == "0" || value == "" {
+		return Limit{}, nil
+	}
+
+	index := strings.Index(value, "/")
+	if index < 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	requests, err := strconv.Atoi(value[:index])
+	if err != nil || requests < 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	period, err := time.ParseDuration(value[index+1:])
+	if err != nil || period <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	return Limit{requests, period}, nil
+}
+
+// Enabled report whether limit allows finite number of requests
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// rate tokens refilled per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result of taking token from bucket
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter wait until next token, zero when allowed
+	RetryAfter time.Duration
+	// Reset wait until bucket is full
+	Reset time.Duration
+}
+
+// Store interface for token buckets, implemented by in-memory store and can be backed by shared storage
+// such as redis so that limits hold across instances
+type Store interface {
+	// Take remove one token from bucket of key
+	Take(key string, limit Limit, now time.Time) (Result, error)
+}
+
+// ErrResponse return error response with message
+type ErrResponse struct {
+	Error string `json:"error"`
+}
+
+// Config of rate limit middleware
+type Config struct {
+	// Name prefix of bucket key, separates budgets of routes sharing a store
+	Name  string
+	Store Store
+	Limit Limit
+	// Key return identity of client, client ip by default
+	Key func(c *fiber.Ctx) string
+	// Next skip limit of request when it returns true
+	Next func(c *fiber.Ctx) bool
+}
+
+// New initial rate limit middleware, it sets X-RateLimit-* headers and
+// responds 429 with Retry-After when bucket of client is empty
+func New(config Config) fiber.Handler {
+	if !config.Limit.Enabled() {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	if config.Key == nil {
+		config.Key = func(c *fiber.Ctx) string {
+			return c.IP()
+		}
+	}
+
+	limit := strconv.Itoa(config.Limit.Requests)
+	return func(c *fiber.Ctx) error {
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+
+		result, err := config.Store.Take(config.Name+":"+config.Key(c), config.Limit, time.Now())
+		if errors.Is(err, ErrStoreFull) {
+			// new client is rejected, otherwise client rotating its ip could fill store and switch off limits
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds(secondsDuration(1/config.Limit.rate()))))
+			return c.Status(fiber.StatusTooManyRequests).JSON(ErrResponse{ErrTooManyRequests.Error()})
+		} else if err != nil {
+			// fail open so that unavailable store does not take the service down
+			log.Printf("could not take rate limit token: %v", err)
+			return c.Next()
+		}
+
+		c.Set("X-RateLimit-Limit", limit)
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("X-RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds(result.RetryAfter)))
+			return c.Status(fiber.StatusTooManyRequests).JSON(ErrResponse{ErrTooManyRequests.Error()})
+		}
+		return c.Next()
+	}
+}
+
+// seconds round duration up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
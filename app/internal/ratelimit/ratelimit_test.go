@@ -0,0 +1,192 @@
+package ratelimit
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{"should disable limit", "0", Limit{}, false},
+		{"should parse limit", "10/1m", Limit{10, time.Minute}, false},
+		{"should return error without period", "10", Limit{}, true},
+		{"should return error on invalid requests", "ten/1m", Limit{}, true},
+		{"should return error on zero period", "10/0s", Limit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	store := NewMemoryStore(10)
+	limit := Limit{2, time.Minute}
+	now := time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)
+
+	for i, want := range []bool{true, true, false} {
+		result, _ := store.Take("a", limit, now)
+		if result.Allowed != want {
+			t.Errorf("Take() #%d allowed = %v, want %v", i, result.Allowed, want)
+		}
+	}
+
+	result, _ := store.Take("a", limit, now)
+	if result.RetryAfter != 30*time.Second || result.Reset != time.Minute {
+		t.Errorf("Take() retry after = %v, reset = %v", result.RetryAfter, result.Reset)
+	}
+
+	// other key has its own bucket
+	if result, _ := store.Take("b", limit, now); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("Take() of other key = %+v", result)
+	}
+
+	// one token is refilled after half of period
+	if result, _ := store.Take("a", limit, now.Add(30*time.Second)); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Take() after refill = %+v", result)
+	}
+}
+
+func TestMemoryStore_Evict(t *testing.T) {
+	store := NewMemoryStore(2)
+	limit := Limit{1, time.Minute}
+	now := time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)
+
+	_, _ = store.Take("a", limit, now)
+	_, _ = store.Take("b", limit, now.Add(30*time.Second))
+	_, _ = store.Take("c", limit, now.Add(time.Minute))
+
+	if _, ok := store.buckets["a"]; ok {
+		t.Errorf("full bucket of a is not evicted")
+	}
+	if len(store.buckets) != 2 {
+		t.Errorf("len(buckets) = %d, want 2", len(store.buckets))
+	}
+}
+
+func TestMemoryStore_Full(t *testing.T) {
+	store := NewMemoryStore(2)
+	limit := Limit{2, time.Minute}
+	now := time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)
+
+	_, _ = store.Take("a", limit, now)
+	_, _ = store.Take("b", limit, now)
+
+	// buckets in use are not evicted to make room so their limits are kept
+	if _, err := store.Take("c", limit, now); err != ErrStoreFull {
+		t.Errorf("Take() error = %v, want %v", err, ErrStoreFull)
+	}
+	if result, _ := store.Take("a", limit, now); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Take() of tracked key = %+v", result)
+	}
+
+	// least recently used bucket is evicted once it is full again
+	if _, err := store.Take("c", limit, now.Add(time.Minute)); err != nil {
+		t.Errorf("Take() after refill error = %v", err)
+	}
+	if _, ok := store.buckets["b"]; ok {
+		t.Errorf("least recently used bucket of b is not evicted")
+	}
+}
+
+func TestNew(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", New(Config{
+		Name:  "test",
+		Store: NewMemoryStore(10),
+		Limit: Limit{1, time.Minute},
+	}), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	res, _ := app.Test(httptest.NewRequest("GET", "/", nil), -1)
+	if res.StatusCode != fiber.StatusOK {
+		t.Errorf("status = %v, want %v", res.StatusCode, fiber.StatusOK)
+	}
+	if res.Header.Get("X-RateLimit-Limit") != "1" || res.Header.Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("headers = %v", res.Header)
+	}
+
+	res, _ = app.Test(httptest.NewRequest("GET", "/", nil), -1)
+	if res.StatusCode != fiber.StatusTooManyRequests {
+		t.Errorf("status = %v, want %v", res.StatusCode, fiber.StatusTooManyRequests)
+	}
+	if res.Header.Get(fiber.HeaderRetryAfter) != "60" {
+		t.Errorf("Retry-After = %v, want 60", res.Header.Get(fiber.HeaderRetryAfter))
+	}
+}
+
+func TestNew_StoreFull(t *testing.T) {
+	app := fiber.New()
+	store := NewMemoryStore(1)
+	app.Get("/", New(Config{
+		Name:  "test",
+		Store: store,
+		Limit: Limit{2, time.Minute},
+		Key: func(c *fiber.Ctx) string {
+			return c.Get("X-Client")
+		},
+		Next: func(c *fiber.Ctx) bool {
+			return c.Get("X-Client") == ""
+		},
+	}), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	send := func(client string) *http.Response {
+		req := httptest.NewRequest("GET", "/", nil)
+		if client != "" {
+			req.Header.Add("X-Client", client)
+		}
+		res, _ := app.Test(req, -1)
+		return res
+	}
+
+	if res := send("a"); res.StatusCode != fiber.StatusOK {
+		t.Errorf("status = %v, want %v", res.StatusCode, fiber.StatusOK)
+	}
+	// new client is not let through while every tracked bucket is in use
+	res := send("b")
+	if res.StatusCode != fiber.StatusTooManyRequests {
+		t.Errorf("status of new client = %v, want %v", res.StatusCode, fiber.StatusTooManyRequests)
+	}
+	if res.Header.Get(fiber.HeaderRetryAfter) != "30" {
+		t.Errorf("Retry-After = %v, want 30", res.Header.Get(fiber.HeaderRetryAfter))
+	}
+	// skipped request does not take token
+	for i := 0; i < 3; i++ {
+		if res := send(""); res.StatusCode != fiber.StatusOK {
+			t.Errorf("status of skipped request = %v, want %v", res.StatusCode, fiber.StatusOK)
+		}
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", New(Config{}), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	for i := 0; i < 3; i++ {
+		res, _ := app.Test(httptest.NewRequest("GET", "/", nil), -1)
+		if res.StatusCode != fiber.StatusOK {
+			t.Errorf("status = %v, want %v", res.StatusCode, fiber.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package url
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"os"
+	"rabbit-shorten-url/internal/url/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBlockListDisabled = errors.New("block list is disabled")
+
+	// BlockKinds allowed kinds of block rule
+	BlockKinds = []interface{}{models.BlockDomain, models.BlockSuffix, models.BlockRegex}
+)
+
+// compiledRules block rules grouped by kind for matching
+type compiledRules struct {
+	domains  map[string]bool
+	suffixes []string
+	regexes  []*regexp.Regexp
+}
+
+// BlockList match host of url against block rules stored in repository and file,
+// rules are reloaded every interval after Start or on Reload
+type BlockList struct {
+	repo     Repository
+	file     string
+	interval time.Duration
+
+	mu       sync.RWMutex
+	rules    compiledRules
+	loadedAt time.Time
+
+	worker worker
+}
+
+// NewBlockList initial block list of rules in repository and optional file, Reload must be called to load rules
+func NewBlockList(repo Repository, file string, interval time.Duration) *BlockList {
+	return &BlockList{
+		repo:     repo,
+		file:     file,
+		interval: interval,
+		worker:   newWorker(),
+	}
+}
+
+// Reload load rules from repository and file, current rules are kept when loading fails
+func (b *BlockList) Reload() error {
+	rules, err := b.repo.ListBlockRules()
+	if err != nil {
+		return err
+	}
+	if b.file != "" {
+		fileRules, err := readBlockRules(b.file)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	compiled := compiledRules{domains: map[string]bool{}}
+	for _, rule := range rules {
+		switch rule.Kind {
+		case models.BlockDomain:
+			compiled.domains[strings.ToLower(rule.Pattern)] = true
+		case models.BlockSuffix:
+			compiled.suffixes = append(compiled.suffixes, strings.ToLower(strings.TrimPrefix(rule.Pattern, ".")))
+		case models.BlockRegex:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("block rule %q: %v", rule.Pattern, err)
+			}
+			compiled.regexes = append(compiled.regexes, re)
+		default:
+			return fmt.Errorf("block rule %q: unknown kind %q", rule.Pattern, rule.Kind)
+		}
+	}
+
+	b.mu.Lock()
+	b.rules = compiled
+	b.loadedAt = time.Now()
+	b.mu.Unlock()
+	return nil
+}
+
+// readBlockRules read rules of file, each line is "<kind> <pattern>", empty lines and lines starting with # are skipped
+func readBlockRules(path string) ([]models.BlockRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules := []models.BlockRule{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: must be <kind> <pattern>", path, line)
+		}
+		rule := models.BlockRule{Kind: fields[0], Pattern: fields[1]}
+		if err := validateBlockRule(rule); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// Check custom rule for block list validation, host of url is matched so "notfacebook.example" is not "facebook.com"
+func (b *BlockList) Check(value interface{}) error {
+	s, _ := value.(string)
+	host := urlHost(s)
+	if host == "" {
+		// invalid url is reported by is.URL
+		return nil
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.rules.domains[host] {
+		return ErrURLBlockList
+	}
+	for _, suffix := range b.rules.suffixes {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return ErrURLBlockList
+		}
+	}
+	for _, re := range b.rules.regexes {
+		if re.MatchString(host) {
+			return ErrURLBlockList
+		}
+	}
+	return nil
+}
+
+// LoadedAt return time of last successful reload
+func (b *BlockList) LoadedAt() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.loadedAt
+}
+
+// Start reload rules every interval in background until Stop, changes of file and other instances are picked up
+func (b *BlockList) Start() {
+	b.worker.run(b.interval, func() {
+		if err := b.Reload(); err != nil {
+			log.Printf("could not reload block list: %v", err)
+		}
+	})
+}
+
+// Stop background reload
+func (b *BlockList) Stop() {
+	b.worker.stopWait()
+}
+
+// validateBlockRule validate kind and pattern of block rule
+func validateBlockRule(rule models.BlockRule) error {
+	if err := validation.Validate(rule.Kind, validation.Required, validation.In(BlockKinds...)); err != nil {
+		return errors.New("kind: " + err.Error())
+	}
+
+	rules := []validation.Rule{validation.Required}
+	if rule.Kind == models.BlockRegex {
+		rules = append(rules, validation.By(checkRegex))
+	} else {
+		rules = append(rules, is.Domain)
+	}
+	if err := validation.Validate(strings.TrimPrefix(rule.Pattern, "."), rules...); err != nil {
+		return errors.New("pattern: " + err.Error())
+	}
+	return nil
+}
+
+// checkRegex custom rule for regular expression validation
+func checkRegex(value interface{}) error {
+	s, _ := value.(string)
+	_, err := regexp.Compile(s)
+	return err
+}
+
+// BlockListResponse return block rules of repository and time of last reload
+type BlockListResponse struct {
+	Rules    []models.BlockRule `json:"rules"`
+	LoadedAt time.Time          `json:"loaded_at"`
+}
+
+// BlockRules is used to list block rules stored in repository, rules of file are not listed
+func (u *service) BlockRules(c *fiber.Ctx) error {
+	if u.config.BlockList == nil {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrBlockListDisabled.Error()})
+	}
+
+	rules, err := u.repo.ListBlockRules()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+	return c.JSON(BlockListResponse{rules, u.config.BlockList.LoadedAt()})
+}
+
+// AddBlockRule is used to store block rule and reload block list
+func (u *service) AddBlockRule(c *fiber.Ctx) error {
+	if u.config.BlockList == nil {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrBlockListDisabled.Error()})
+	}
+
+	rule := new(models.BlockRule)
+	if err := c.BodyParser(rule); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+	}
+	rule.ID = 0
+	rule.Kind = strings.ToLower(rule.Kind)
+	if err := validateBlockRule(*rule); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+	}
+
+	if err := u.repo.InsertBlockRule(rule); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+	if err := u.config.BlockList.Reload(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// DeleteBlockRule is used to remove block rule by id and reload block list
+func (u *service) DeleteBlockRule(c *fiber.Ctx) error {
+	if u.config.BlockList == nil {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrBlockListDisabled.Error()})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	}
+
+	err = u.repo.DeleteBlockRule(uint(id))
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+	if err := u.config.BlockList.Reload(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	return c.JSON(SuccessResponse{"block rule " + c.Params("id") + " has been deleted"})
+}
+
+// ReloadBlockList is used to reload block list after file is changed
+func (u *service) ReloadBlockList(c *fiber.Ctx) error {
+	if u.config.BlockList == nil {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrBlockListDisabled.Error()})
+	}
+	if err := u.config.BlockList.Reload(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+	return c.JSON(SuccessResponse{"block list has been reloaded"})
+}
@@ -0,0 +1,100 @@
+package url
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"rabbit-shorten-url/internal/url/models"
+	"testing"
+	"time"
+)
+
+func TestBlockList_Check(t *testing.T) {
+	repo := NewMemoryRepository()
+	for _, rule := range []models.BlockRule{
+		{Kind: models.BlockDomain, Pattern: "bad.example"},
+		{Kind: models.BlockSuffix, Pattern: "facebook.com"},
+		{Kind: models.BlockRegex, Pattern: `^ads[0-9]+\.`},
+	} {
+		rule := rule
+		if err := repo.InsertBlockRule(&rule); err != nil {
+			t.Fatalf("InsertBlockRule() error = %v", err)
+		}
+	}
+	b := NewBlockList(repo, "", time.Minute)
+	if err := b.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"should block domain", "https://bad.example/path", true},
+		{"should block domain case insensitive", "https://BAD.example./", true},
+		{"should not block subdomain of domain", "https://www.bad.example/", false},
+		{"should block suffix", "https://www.facebook.com/", true},
+		{"should block suffix itself", "https://facebook.com:443/", true},
+		{"should not block similar host", "https://notfacebook.example/", false},
+		{"should not block host ending with suffix", "https://notfacebook.com/", false},
+		{"should not match path", "https://www.google.com/facebook.com", false},
+		{"should block regex", "http://ads42.example.net/", true},
+		{"should not block other regex", "http://ads.example.net/", false},
+		{"should block url without scheme", "facebook.com/x", true},
+		{"should block host without scheme", "www.facebook.com", true},
+		{"should block host and port without scheme", "facebook.com:443/x", true},
+		{"should ignore invalid url", "://", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := b.Check(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlockList_ReloadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blocklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "blocklist.txt")
+
+	write := func(content string) {
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := NewBlockList(NewMemoryRepository(), file, time.Minute)
+	write("# spam\nsuffix spam.example\n\n")
+	if err := b.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if err := b.Check("https://a.spam.example/"); err == nil {
+		t.Errorf("Check() error = nil, want %v", ErrURLBlockList)
+	}
+
+	// invalid file keeps current rules
+	write("suffix\n")
+	if err := b.Reload(); err == nil {
+		t.Errorf("Reload() error = nil, want error")
+	}
+	if err := b.Check("https://a.spam.example/"); err == nil {
+		t.Errorf("Check() after failed reload error = nil, want %v", ErrURLBlockList)
+	}
+
+	write("domain other.example\n")
+	if err := b.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if err := b.Check("https://a.spam.example/"); err != nil {
+		t.Errorf("Check() after reload error = %v", err)
+	}
+	if err := b.Check("https://other.example/"); err == nil {
+		t.Errorf("Check() error = nil, want %v", ErrURLBlockList)
+	}
+}
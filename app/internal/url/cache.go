@@ -0,0 +1,90 @@
+package url
+
+import (
+	"container/list"
+	"rabbit-shorten-url/internal/url/models"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	url       models.Url
+	expiresAt time.Time
+}
+
+// ResolveCache cache url of short_code for redirect, entries live until ttl or invalidation,
+// least recently used entry is evicted when cache is full
+type ResolveCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order of entries by last use, front is the most recently used
+	order *list.List
+}
+
+// NewResolveCache initial cache with ttl of entry and max number of entries
+func NewResolveCache(ttl time.Duration, maxEntries int) *ResolveCache {
+	return &ResolveCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+// Get return cached url of short_code which is not older than ttl
+func (r *ResolveCache) Get(code string) (models.Url, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.entries[code]
+	if !ok {
+		return models.Url{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		r.remove(element)
+		return models.Url{}, false
+	}
+	r.order.MoveToFront(element)
+	return entry.url, true
+}
+
+// Set cache url by its short_code
+func (r *ResolveCache) Set(url models.Url) {
+	entry := &cacheEntry{url, time.Now().Add(r.ttl)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, ok := r.entries[url.ShortCode]; ok {
+		element.Value = entry
+		r.order.MoveToFront(element)
+		return
+	}
+	if len(r.entries) >= r.maxEntries {
+		r.remove(r.order.Back())
+	}
+	r.entries[url.ShortCode] = r.order.PushFront(entry)
+}
+
+// Invalidate remove cached url of short_code, used after url is changed
+func (r *ResolveCache) Invalidate(code string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if element, ok := r.entries[code]; ok {
+		r.remove(element)
+	}
+}
+
+// remove delete entry of element, caller must hold mu
+func (r *ResolveCache) remove(element *list.Element) {
+	if element == nil {
+		return
+	}
+	r.order.Remove(element)
+	delete(r.entries, element.Value.(*cacheEntry).url.ShortCode)
+}
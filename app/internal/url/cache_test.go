@@ -0,0 +1,42 @@
+package url
+
+import (
+	"rabbit-shorten-url/internal/url/models"
+	"testing"
+	"time"
+)
+
+func TestResolveCache(t *testing.T) {
+	cache := NewResolveCache(time.Hour, 2)
+	cache.Set(models.Url{ShortCode: "test1234", FullUrl: "https://www.google.com"})
+
+	if url, ok := cache.Get("test1234"); !ok || url.FullUrl != "https://www.google.com" {
+		t.Errorf("Get() = %v, %v, want cached url", url, ok)
+	}
+
+	cache.Invalidate("test1234")
+	if _, ok := cache.Get("test1234"); ok {
+		t.Errorf("Get() after Invalidate() is cached")
+	}
+
+	cache.Set(models.Url{ShortCode: "a"})
+	cache.Set(models.Url{ShortCode: "b"})
+	// a is used after b so b is the least recently used
+	cache.Get("a")
+	cache.Set(models.Url{ShortCode: "c"})
+	if len(cache.entries) != 2 {
+		t.Errorf("entries = %v, want at most 2", len(cache.entries))
+	}
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Get() of least recently used entry is cached")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("Get() of recently used entry is not cached")
+	}
+
+	expired := NewResolveCache(-time.Second, 2)
+	expired.Set(models.Url{ShortCode: "test1234"})
+	if _, ok := expired.Get("test1234"); ok {
+		t.Errorf("Get() after ttl is cached")
+	}
+}
@@ -0,0 +1,86 @@
+package url
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"html/template"
+	"time"
+)
+
+const (
+	defaultCodeLength   = 8
+	defaultRedirectType = fiber.StatusFound
+)
+
+// Config of url service, zero value is replaced by default
+type Config struct {
+	// CodeGenerator strategy to generate short_code, default is random with length 8
+	CodeGenerator CodeGenerator
+	// ReuseExisting return existing short_code of the same url on create, default is false
+	ReuseExisting bool
+	// IpHashSalt secret key of hmac of client ip in click events, the app refuses to start without it
+	IpHashSalt string
+	// HitCounter buffer hits and click events of redirect, they are written synchronously when it is nil
+	HitCounter *HitCounter
+	// ErrorPage html page of redirect errors for browsers, errors are always json when it is nil
+	ErrorPage *template.Template
+	// Cache cache url of short_code for redirect, every redirect reads repository when it is nil
+	Cache *ResolveCache
+	// DefaultRedirectType status of redirect when it is not set on url, default is 302
+	DefaultRedirectType int
+	// PurgeRetention default retention of deleted or expired urls for purge endpoint, purge requires retention query when it is zero
+	PurgeRetention time.Duration
+	// Sweeper expiry sweeper shown to admin, status endpoint is 404 when it is nil
+	Sweeper *Sweeper
+	// BlockList reject urls whose host matches block rules, every url is allowed when it is nil
+	BlockList *BlockList
+	// ThreatFeeds reject urls whose host is listed in local threat feeds, urls are not screened when it is nil
+	ThreatFeeds *ThreatFeeds
+	// OwnHosts hostnames of this service in addition to host of request, urls to them would redirect to itself
+	OwnHosts []string
+	// ShortenerDomains known url shortener domains, default is DefaultShortenerDomains
+	ShortenerDomains []string
+	// ShortenerPolicy reject or resolve urls to own hosts and shortener domains, default is reject
+	ShortenerPolicy string
+	// Resolver follow redirect of shortener domains with resolve policy, default is http client
+	Resolver Resolver
+	// UnlockAttempts wrong passwords of protected url before it is locked, default is 5
+	UnlockAttempts int
+	// UnlockLockout window of wrong passwords and duration of lock, default is 15 minutes
+	UnlockLockout time.Duration
+	// MinExpiry shortest expiry of request from now, default is 1 minute
+	MinExpiry time.Duration
+	// MaxExpiry longest expiry of request from now, default is 10 years
+	MaxExpiry time.Duration
+}
+
+// configDefault set default values of config
+func configDefault(config Config) Config {
+	if config.CodeGenerator == nil {
+		config.CodeGenerator = NewRandomGenerator(defaultCodeLength)
+	}
+	if config.DefaultRedirectType == 0 {
+		config.DefaultRedirectType = defaultRedirectType
+	}
+	if config.ShortenerDomains == nil {
+		config.ShortenerDomains = DefaultShortenerDomains
+	}
+	if config.ShortenerPolicy == "" {
+		config.ShortenerPolicy = PolicyReject
+	}
+	if config.Resolver == nil {
+		config.Resolver = NewHttpResolver()
+	}
+	if config.UnlockAttempts == 0 {
+		config.UnlockAttempts = defaultUnlockAttempts
+	}
+	if config.UnlockLockout == 0 {
+		config.UnlockLockout = defaultUnlockLockout
+	}
+	if config.MinExpiry == 0 {
+		config.MinExpiry = defaultMinExpiry
+	}
+	if config.MaxExpiry == 0 {
+		config.MaxExpiry = defaultMaxExpiry
+	}
+	return config
+}
@@ -0,0 +1,86 @@
+package url
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"html/template"
+	"io/ioutil"
+)
+
+// defaultErrorPage branded html page of redirect errors
+const defaultErrorPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Status}} {{.Title}}</title>
+<style>
+body{font-family:-apple-system,Helvetica,Arial,sans-serif;background:#f6f7f9;color:#333;text-align:center;padding:10vh 1em}
+h1{font-size:4em;margin:0;color:#e5534b}
+p{font-size:1.2em}
+</style>
+</head>
+<body>
+<h1>{{.Status}}</h1>
+<p>{{.Title}}</p>
+<p><small>{{.Message}}</small></p>
+</body>
+</html>
+`
+
+// ErrorPage data of html error page template
+type ErrorPage struct {
+	Status  int
+	Title   string
+	Message string
+	Code    string
+}
+
+// DefaultErrorPage return built-in html error page template
+func DefaultErrorPage() *template.Template {
+	return template.Must(template.New("error").Parse(defaultErrorPage))
+}
+
+// LoadErrorPage parse html error page template from file
+func LoadErrorPage(path string) (*template.Template, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return template.New("error").Parse(string(b))
+}
+
+// errorStatus map error of redirect resolution to http status
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrNotYetActive):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrDeleted), errors.Is(err, ErrExpired), errors.Is(err, ErrDisabled),
+		errors.Is(err, ErrExhausted):
+		return fiber.StatusGone
+	}
+	return fiber.StatusInternalServerError
+}
+
+// sendError respond error of redirect resolution as html page when it is configured and accepted, otherwise json
+func (u *service) sendError(c *fiber.Ctx, code string, err error) error {
+	status := errorStatus(err)
+
+	if u.config.ErrorPage != nil && c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML) == fiber.MIMETextHTML {
+		var buf bytes.Buffer
+		page := ErrorPage{
+			Status:  status,
+			Title:   utils.StatusMessage(status),
+			Message: err.Error(),
+			Code:    code,
+		}
+		if err := u.config.ErrorPage.Execute(&buf, page); err == nil {
+			c.Type("html")
+			return c.Status(status).Send(buf.Bytes())
+		}
+	}
+
+	return c.Status(status).JSON(ErrResponse{err.Error()})
+}
@@ -0,0 +1,186 @@
+package url
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMinExpiry = time.Minute
+	defaultMaxExpiry = 10 * 365 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidExpiry is the error in case of expiry is not in any accepted form
+	ErrInvalidExpiry = errors.New("expiry: must be ISO-8601 duration, Go duration, RFC 3339 time or number of hours")
+	// ErrNegativeExpiry is the error in case of expiry is a negative duration
+	ErrNegativeExpiry = errors.New("expiry: must not be negative")
+	// ErrExpiryTooLarge is the error in case of expiry does not fit in time.Duration, about 292 years
+	ErrExpiryTooLarge = errors.New("expiry: is too large")
+
+	// regExIsoDuration ISO-8601 duration like P1Y2M3W4DT5H6M7.5S
+	regExIsoDuration = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+	// regExDays leading days of Go duration like 7d or 1d12h
+	regExDays = regexp.MustCompile(`^(\d+)d(.*)$`)
+)
+
+// Expiry of create and update request, accepted forms are ISO-8601 duration ("P7D", "PT30M"), Go duration with
+// optional days ("30m", "7d", "1d12h"), RFC 3339 time ("2021-03-01T09:00:00Z") and json number of hours which is
+// kept for existing clients, zero duration means never expire
+type Expiry struct {
+	years, months, days int
+	duration            time.Duration
+	at                  *time.Time
+}
+
+// ParseExpiry parse expiry from string, empty string means never expire
+func ParseExpiry(s string) (Expiry, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return Expiry{}, nil
+	case strings.HasPrefix(s, "P"):
+		return parseIsoDuration(s)
+	case strings.HasPrefix(s, "-"):
+		return Expiry{}, ErrNegativeExpiry
+	}
+
+	if at, err := time.Parse(time.RFC3339, s); err == nil {
+		return Expiry{at: &at}, nil
+	}
+
+	var e Expiry
+	if m := regExDays.FindStringSubmatch(s); m != nil {
+		days, err := strconv.Atoi(m[1])
+		if err != nil {
+			// only digits are matched so error is out of range
+			return Expiry{}, ErrExpiryTooLarge
+		}
+		e.days = days
+		if s = m[2]; s == "" {
+			if err := e.validateSpan(0); err != nil {
+				return Expiry{}, err
+			}
+			return e, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return Expiry{}, ErrInvalidExpiry
+	}
+	if err := e.validateSpan(d.Seconds()); err != nil {
+		return Expiry{}, err
+	}
+	e.duration = d
+	return e, nil
+}
+
+// parseIsoDuration parse ISO-8601 duration, years, months, weeks and days are calendar units of local time
+func parseIsoDuration(s string) (Expiry, error) {
+	m := regExIsoDuration.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return Expiry{}, ErrInvalidExpiry
+	}
+
+	var n [6]int
+	for i := range n {
+		if m[i+1] == "" {
+			continue
+		}
+		v, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			// only digits are matched so error is out of range
+			return Expiry{}, ErrExpiryTooLarge
+		}
+		n[i] = v
+	}
+	var seconds float64
+	if m[7] != "" {
+		seconds, _ = strconv.ParseFloat(strings.Replace(m[7], ",", ".", 1), 64)
+	}
+
+	// span is validated before units are multiplied so that they can not overflow
+	e := Expiry{years: n[0], months: n[1], days: n[3]}
+	if err := e.validateSpan(float64(n[2])*7*24*3600 + float64(n[4])*3600 + float64(n[5])*60 + seconds); err != nil {
+		return Expiry{}, err
+	}
+	e.days += n[2] * 7
+	e.duration = time.Duration(n[4])*time.Hour + time.Duration(n[5])*time.Minute + time.Duration(seconds*float64(time.Second))
+	return e, nil
+}
+
+// validateSpan return ErrExpiryTooLarge when calendar units of expiry with seconds do not fit in time.Duration,
+// years and months are counted as their longest length
+func (e Expiry) validateSpan(seconds float64) error {
+	days := float64(e.years)*366 + float64(e.months)*31 + float64(e.days)
+	if (days*24*3600+seconds)*float64(time.Second) >= math.MaxInt64 {
+		return ErrExpiryTooLarge
+	}
+	return nil
+}
+
+// UnmarshalJSON parse expiry from json string or number of hours
+func (e *Expiry) UnmarshalJSON(b []byte) error {
+	var hours float64
+	if err := json.Unmarshal(b, &hours); err == nil {
+		if hours < 0 {
+			return ErrNegativeExpiry
+		}
+		if hours >= math.MaxInt64/float64(time.Hour) {
+			// duration in nanoseconds sent as number would overflow
+			return ErrExpiryTooLarge
+		}
+		*e = Expiry{duration: time.Duration(hours * float64(time.Hour))}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return ErrInvalidExpiry
+	}
+	parsed, err := ParseExpiry(s)
+	if err != nil {
+		return err
+	}
+	*e = parsed
+	return nil
+}
+
+// IsZero report whether expiry means never expire
+func (e Expiry) IsZero() bool {
+	return e.at == nil && e.years == 0 && e.months == 0 && e.days == 0 && e.duration == 0
+}
+
+// Date return expiry date from now, nil means never expire
+func (e Expiry) Date(now time.Time) *time.Time {
+	if e.at != nil {
+		at := *e.at
+		return &at
+	}
+	if e.IsZero() {
+		return nil
+	}
+	date := now.AddDate(e.years, e.months, e.days).Add(e.duration)
+	return &date
+}
+
+// expiryDate return expiry date of request from now within Config.MinExpiry and Config.MaxExpiry, nil means never expire
+func (u *service) expiryDate(expiry Expiry, now time.Time) (*time.Time, error) {
+	date := expiry.Date(now)
+	if date == nil {
+		return nil, nil
+	}
+	if date.Before(now.Add(u.config.MinExpiry)) {
+		return nil, fmt.Errorf("expiry: must be at least %s from now", u.config.MinExpiry)
+	}
+	if date.After(now.Add(u.config.MaxExpiry)) {
+		return nil, fmt.Errorf("expiry: must be at most %s from now", u.config.MaxExpiry)
+	}
+	return date, nil
+}
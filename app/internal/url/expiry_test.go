@@ -0,0 +1,72 @@
+package url
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExpiry_UnmarshalJSON(t *testing.T) {
+	now := time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    *time.Time
+		wantErr bool
+	}{
+		{"should never expire on zero hours", `0`, nil, false},
+		{"should never expire on empty string", `""`, nil, false},
+		{"should parse hours", `24`, timePtr(now.Add(24 * time.Hour)), false},
+		{"should parse fraction of hours", `1.5`, timePtr(now.Add(90 * time.Minute)), false},
+		{"should parse ISO-8601 days", `"P7D"`, timePtr(now.AddDate(0, 0, 7)), false},
+		{"should parse ISO-8601 minutes", `"PT30M"`, timePtr(now.Add(30 * time.Minute)), false},
+		{"should parse ISO-8601 date and time", `"P1M1W1DT2H0.5S"`, timePtr(now.AddDate(0, 1, 8).Add(2*time.Hour + 500*time.Millisecond)), false},
+		{"should parse Go duration", `"30m"`, timePtr(now.Add(30 * time.Minute)), false},
+		{"should parse days of Go duration", `"7d"`, timePtr(now.AddDate(0, 0, 7)), false},
+		{"should parse days and Go duration", `"1d12h"`, timePtr(now.AddDate(0, 0, 1).Add(12 * time.Hour)), false},
+		{"should parse RFC 3339 time", `"2021-03-01T09:00:00Z"`, timePtr(time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)), false},
+		{"should return error on negative hours", `-1`, nil, true},
+		{"should return error on negative duration", `"-30m"`, nil, true},
+		{"should return error on hours overflow", `86400000000000`, nil, true},
+		{"should return error on days out of range", `"99999999999999999999d"`, nil, true},
+		{"should return error on days overflow", `"106752d"`, nil, true},
+		{"should return error on days and duration overflow", `"106751d24h"`, nil, true},
+		{"should return error on ISO-8601 hours overflow", `"PT2562048H"`, nil, true},
+		{"should return error on ISO-8601 years overflow", `"P9999999999999Y"`, nil, true},
+		{"should return error on ISO-8601 weeks out of range", `"P99999999999999999999W"`, nil, true},
+		{"should return error on ISO-8601 seconds overflow", `"PT9300000000S"`, nil, true},
+		{"should return error on empty ISO-8601 duration", `"PT"`, nil, true},
+		{"should return error on unknown unit", `"7x"`, nil, true},
+		{"should return error on boolean", `true`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e Expiry
+			err := json.Unmarshal([]byte(tt.value), &e)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := e.Date(now)
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+				t.Errorf("Date() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpiry_TooLarge(t *testing.T) {
+	for _, value := range []string{"99999999999999999999d", "106752d", "PT2562048H", "P300Y", "P1000000M", "PT9300000000S"} {
+		if _, err := ParseExpiry(value); err != ErrExpiryTooLarge {
+			t.Errorf("ParseExpiry(%q) error = %v, want %v", value, err, ErrExpiryTooLarge)
+		}
+	}
+	// the longest expiry which fits in time.Duration is kept
+	if _, err := ParseExpiry("P290Y"); err != nil {
+		t.Errorf("ParseExpiry() error = %v", err)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
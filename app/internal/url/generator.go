@@ -0,0 +1,204 @@
+package url
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"math/bits"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// base62Chars alphabet of generated short_code
+	base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// sequentialMaxLength is the longest short_code that 62^length still fits in uint64
+	sequentialMaxLength = 10
+	// feistelRounds rounds of keyed permutation of sequential counter
+	feistelRounds = 6
+	// hashDeterministicAttempts attempts of hash strategy derived from full_url only, later attempts are salted randomly
+	hashDeterministicAttempts = 3
+
+	StrategyRandom     = "random"
+	StrategySequential = "sequential"
+	StrategyHash       = "hash"
+)
+
+var (
+	// ErrCodeLength is the error in case of short_code length is not supported by strategy
+	ErrCodeLength = errors.New("short code length is not supported")
+	// ErrCodeStrategy is the error in case of unknown strategy
+	ErrCodeStrategy = errors.New("short code strategy is not supported")
+	// ErrCodeSecret is the error in case of sequential strategy has no secret to permute counter
+	ErrCodeSecret = errors.New("short code secret is required by sequential strategy")
+)
+
+// CodeGenerator interface for short_code generation strategies,
+// attempt is increased when previous generated code is already used
+type CodeGenerator interface {
+	Generate(fullUrl string, attempt int) (string, error)
+}
+
+// NewCodeGenerator initial CodeGenerator by strategy name and length of short_code,
+// secret is the key of sequential strategy and is ignored by other strategies
+func NewCodeGenerator(strategy string, length int, secret string) (CodeGenerator, error) {
+	if length < aliasMinLength || length > aliasMaxLength {
+		return nil, ErrCodeLength
+	}
+
+	switch strategy {
+	case StrategyRandom, "":
+		return NewRandomGenerator(length), nil
+	case StrategySequential:
+		if length > sequentialMaxLength {
+			return nil, ErrCodeLength
+		}
+		if secret == "" {
+			return nil, ErrCodeSecret
+		}
+		// start from current time in millisecond so counter does not repeat codes after restart
+		return NewSequentialGenerator(length, []byte(secret), uint64(time.Now().UnixNano()/int64(time.Millisecond))), nil
+	case StrategyHash:
+		return NewHashGenerator(length), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrCodeStrategy, strategy)
+}
+
+type randomGenerator struct {
+	length int
+}
+
+// NewRandomGenerator initial crypto-random base62 generator
+func NewRandomGenerator(length int) *randomGenerator {
+	return &randomGenerator{
+		length: length,
+	}
+}
+
+func (g *randomGenerator) Generate(_ string, _ int) (string, error) {
+	// reject bytes above the largest multiple of 62 to keep distribution uniform
+	const maxByte = 256 - 256%len(base62Chars)
+
+	var b strings.Builder
+	buf := make([]byte, g.length*2)
+	for b.Len() < g.length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, c := range buf {
+			if int(c) >= maxByte {
+				continue
+			}
+			b.WriteByte(base62Chars[int(c)%len(base62Chars)])
+			if b.Len() == g.length {
+				break
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+type sequentialGenerator struct {
+	length  int
+	space   uint64
+	secret  []byte
+	half    uint
+	counter uint64
+}
+
+// NewSequentialGenerator initial counter based generator starting after start, counter is permuted within 62^length
+// by feistel network keyed with secret so codes are not guessable from previous ones without secret
+func NewSequentialGenerator(length int, secret []byte, start uint64) *sequentialGenerator {
+	space := uint64(1)
+	for i := 0; i < length; i++ {
+		space *= uint64(len(base62Chars))
+	}
+	return &sequentialGenerator{
+		length: length,
+		space:  space,
+		secret: secret,
+		// feistel network permutes 2^(2*half) values which is the smallest even power of two covering space
+		half:    uint(bits.Len64(space-1)+1) / 2,
+		counter: start,
+	}
+}
+
+func (g *sequentialGenerator) Generate(_ string, _ int) (string, error) {
+	n := atomic.AddUint64(&g.counter, 1) % g.space
+
+	// permutation of 2^(2*half) values is walked until it falls in space so it is a permutation of space too,
+	// 2^(2*half) is less than 4 times of space so it takes a few steps
+	mac := hmac.New(sha256.New, g.secret)
+	n = g.permute(mac, n)
+	for n >= g.space {
+		n = g.permute(mac, n)
+	}
+
+	return encodeBase62(n, g.length), nil
+}
+
+// permute encrypt n with balanced feistel network whose round function is hmac of round and right half
+func (g *sequentialGenerator) permute(mac hash.Hash, n uint64) uint64 {
+	mask := uint64(1)<<g.half - 1
+	left, right := n>>g.half, n&mask
+
+	var buf [9]byte
+	for round := 0; round < feistelRounds; round++ {
+		buf[0] = byte(round)
+		binary.BigEndian.PutUint64(buf[1:], right)
+		mac.Reset()
+		mac.Write(buf[:])
+		f := binary.BigEndian.Uint64(mac.Sum(nil)) & mask
+		left, right = right, left^f
+	}
+	return left<<g.half | right
+}
+
+type hashGenerator struct {
+	length int
+}
+
+// NewHashGenerator initial generator which derive short_code from sha256 of full_url,
+// codes of first attempts are deterministic and later ones are random
+func NewHashGenerator(length int) *hashGenerator {
+	return &hashGenerator{
+		length: length,
+	}
+}
+
+func (g *hashGenerator) Generate(fullUrl string, attempt int) (string, error) {
+	input := fullUrl
+	if attempt >= hashDeterministicAttempts {
+		// the same url shortened many times without reuse has used every deterministic code
+		salt := make([]byte, 8)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		input = fmt.Sprintf("%s#%x", fullUrl, salt)
+	} else if attempt > 0 {
+		// salt with attempt to get another code when previous one is used
+		input = fmt.Sprintf("%s#%d", fullUrl, attempt)
+	}
+	sum := sha256.Sum256([]byte(input))
+
+	var b strings.Builder
+	for i := 0; i < g.length; i++ {
+		b.WriteByte(base62Chars[int(sum[i%len(sum)])%len(base62Chars)])
+	}
+	return b.String(), nil
+}
+
+// encodeBase62 encode n to base62 padded with leading zero to length
+func encodeBase62(n uint64, length int) string {
+	code := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		code[i] = base62Chars[n%uint64(len(base62Chars))]
+		n /= uint64(len(base62Chars))
+	}
+	return string(code)
+}
@@ -0,0 +1,161 @@
+package url
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewCodeGenerator(t *testing.T) {
+	type args struct {
+		strategy string
+		length   int
+		secret   string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr error
+	}{
+		{
+			"should return random generator by default",
+			args{strategy: "", length: 8},
+			nil,
+		},
+		{
+			"should return sequential generator",
+			args{strategy: StrategySequential, length: 8, secret: "secret"},
+			nil,
+		},
+		{
+			"should return hash generator",
+			args{strategy: StrategyHash, length: 8},
+			nil,
+		},
+		{
+			"should return error on unknown strategy",
+			args{strategy: "unknown", length: 8},
+			ErrCodeStrategy,
+		},
+		{
+			"should return error on sequential generator without secret",
+			args{strategy: StrategySequential, length: 8},
+			ErrCodeSecret,
+		},
+		{
+			"should return error on too short length",
+			args{strategy: StrategyRandom, length: 2},
+			ErrCodeLength,
+		},
+		{
+			"should return error on too long sequential length",
+			args{strategy: StrategySequential, length: 11},
+			ErrCodeLength,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCodeGenerator(tt.args.strategy, tt.args.length, tt.args.secret); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewCodeGenerator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_randomGenerator(t *testing.T) {
+	type args struct {
+		length int
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"should contains 8 random character",
+			args{length: 8},
+			"12345678",
+		},
+		{
+			"should contains 4 random character",
+			args{length: 4},
+			"1234",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRandomGenerator(tt.args.length).Generate("", 0)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if len(got) != len(tt.want) || strings.Trim(got, base62Chars) != "" {
+				t.Errorf("Generate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sequentialGenerator(t *testing.T) {
+	// with length 3 the whole space is 62^3, every code must be unique
+	g := NewSequentialGenerator(3, []byte("secret"), 0)
+	seen := map[string]bool{}
+	for i := uint64(0); i < g.space; i++ {
+		got, _ := g.Generate("", 0)
+		if len(got) != 3 {
+			t.Fatalf("Generate() = %v, want 3 characters", got)
+		}
+		if seen[got] {
+			t.Fatalf("Generate() = %v is duplicated after %d codes", got, i)
+		}
+		seen[got] = true
+	}
+
+	// distance between consecutive codes is not constant so next code can not be derived from previous ones
+	g = NewSequentialGenerator(8, []byte("secret"), 0)
+	codes := make([]uint64, 3)
+	for i := range codes {
+		code, _ := g.Generate("", 0)
+		codes[i] = decodeBase62(code)
+	}
+	if codes[1]-codes[0] == codes[2]-codes[1] {
+		t.Errorf("Generate() = %v, want not guessable", codes)
+	}
+
+	first, _ := NewSequentialGenerator(8, []byte("secret"), 0).Generate("", 0)
+	other, _ := NewSequentialGenerator(8, []byte("other"), 0).Generate("", 0)
+	if first == other {
+		t.Errorf("Generate() = %v with another secret, want another code", other)
+	}
+}
+
+// decodeBase62 decode code of encodeBase62
+func decodeBase62(code string) uint64 {
+	var n uint64
+	for _, c := range code {
+		n = n*uint64(len(base62Chars)) + uint64(strings.IndexRune(base62Chars, c))
+	}
+	return n
+}
+
+func Test_hashGenerator(t *testing.T) {
+	g := NewHashGenerator(8)
+	first, _ := g.Generate("https://www.google.com", 0)
+	again, _ := g.Generate("https://www.google.com", 0)
+	retry, _ := g.Generate("https://www.google.com", 1)
+
+	if len(first) != 8 {
+		t.Errorf("Generate() = %v, want 8 characters", first)
+	}
+	if first != again {
+		t.Errorf("Generate() = %v then %v, want deterministic", first, again)
+	}
+	if first == retry {
+		t.Errorf("Generate() = %v on retry, want another code", retry)
+	}
+
+	salted, _ := g.Generate("https://www.google.com", hashDeterministicAttempts)
+	saltedAgain, _ := g.Generate("https://www.google.com", hashDeterministicAttempts)
+	if salted == saltedAgain {
+		t.Errorf("Generate() = %v then %v after deterministic attempts, want random", salted, saltedAgain)
+	}
+}
@@ -0,0 +1,392 @@
+package url
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"rabbit-shorten-url/internal/url/models"
+	"strings"
+	"time"
+)
+
+type gormRepository struct {
+	db *gorm.DB
+}
+
+// NewGormRepository initial url repository with dbClient
+func NewGormRepository(dbClient *gorm.DB) *gormRepository {
+	return &gormRepository{
+		db: dbClient,
+	}
+}
+
+func (r *gormRepository) GetByCode(code string) (models.Url, error) {
+	var url models.Url
+	result := r.db.First(&url, "short_code", code)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return url, ErrNotFound
+	}
+	return url, result.Error
+}
+
+// mysqlErrDuplicateEntry error number of duplicate key on unique index
+const mysqlErrDuplicateEntry = 1062
+
+func (r *gormRepository) GetReusable(query ReuseQuery) (models.Url, error) {
+	var url models.Url
+	db := r.db.
+		Where("url_hash = ? AND is_deleted = ? AND is_disabled = ? AND status <> ?", query.UrlHash, false, false, models.StatusExpired).
+		Where("password_hash = ? AND max_hits = ? AND starts_at IS NULL", "", 0).
+		Where("redirect_type IN ?", query.RedirectTypes).
+		Where("owner = ?", query.Owner)
+	if query.ApiKeyID == nil {
+		db = db.Where("api_key_id IS NULL")
+	} else {
+		db = db.Where("api_key_id = ?", *query.ApiKeyID)
+	}
+	if query.ExpiryDate == nil {
+		db = db.Where("expiry_date IS NULL")
+	} else {
+		db = db.Where("expiry_date = ?", *query.ExpiryDate)
+	}
+	result := db.First(&url)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return url, ErrNotFound
+	}
+	return url, result.Error
+}
+
+func (r *gormRepository) Insert(url *models.Url) error {
+	err := r.db.Create(url).Error
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDuplicateEntry {
+		return ErrDuplicated
+	}
+	return err
+}
+
+func (r *gormRepository) IncrementHits(code string, n int) error {
+	return r.db.Model(&models.Url{}).Where("short_code = ?", code).Update("hits", gorm.Expr("hits + ?", n)).Error
+}
+
+func (r *gormRepository) ConsumeHit(code string) error {
+	// condition and increment are one statement so concurrent clicks can not both take the last hit
+	result := r.db.Model(&models.Url{}).
+		Where("short_code = ? AND (max_hits = 0 OR hits < max_hits)", code).
+		Update("hits", gorm.Expr("hits + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected <= 0 {
+		return ErrExhausted
+	}
+	return nil
+}
+
+func (r *gormRepository) Update(url *models.Url, columns []string, history *models.UrlHistory) error {
+	all := map[string]interface{}{
+		"full_url":      url.FullUrl,
+		"url_hash":      url.UrlHash,
+		"expiry_date":   url.ExpiryDate,
+		"redirect_type": url.RedirectType,
+		"status":        url.Status,
+		"threat_feed":   url.ThreatFeed,
+		"flagged_at":    url.FlaggedAt,
+		"is_disabled":   url.IsDisabled,
+	}
+	values := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		values[column] = all[column]
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Url{}).Where("short_code = ?", url.ShortCode).Updates(values)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected <= 0 {
+			return ErrNotFound
+		}
+		return tx.Create(history).Error
+	})
+}
+
+func (r *gormRepository) GetHistory(code string) ([]models.UrlHistory, error) {
+	histories := []models.UrlHistory{}
+	result := r.db.Where("short_code = ?", code).Order("changed_at DESC, id DESC").Find(&histories)
+	return histories, result.Error
+}
+
+func (r *gormRepository) ListBlockRules() ([]models.BlockRule, error) {
+	rules := []models.BlockRule{}
+	result := r.db.Order("id").Find(&rules)
+	return rules, result.Error
+}
+
+func (r *gormRepository) InsertBlockRule(rule *models.BlockRule) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *gormRepository) DeleteBlockRule(id uint) error {
+	result := r.db.Delete(&models.BlockRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected <= 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormRepository) InsertClick(click *models.Click) error {
+	return r.db.Create(click).Error
+}
+
+func (r *gormRepository) InsertClicks(clicks []models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	return r.db.Create(&clicks).Error
+}
+
+func (r *gormRepository) GetClickStats(code string, since time.Time) (ClickStats, error) {
+	var stats ClickStats
+
+	result := r.db.Model(&models.Click{}).
+		Select("COUNT(*) AS total_clicks, COUNT(DISTINCT ip_hash) AS unique_visitors").
+		Where("short_code = ?", code).
+		Scan(&stats)
+	if result.Error != nil {
+		return stats, result.Error
+	}
+
+	var rows []struct {
+		Date           time.Time
+		Clicks         int64
+		UniqueVisitors int64
+	}
+	result = r.db.Model(&models.Click{}).
+		Select("DATE(clicked_at) AS date, COUNT(*) AS clicks, COUNT(DISTINCT ip_hash) AS unique_visitors").
+		Where("short_code = ? AND clicked_at >= ?", code, since).
+		Group("DATE(clicked_at)").
+		Order("date").
+		Scan(&rows)
+	if result.Error != nil {
+		return stats, result.Error
+	}
+
+	stats.Daily = []DailyClicks{}
+	for _, row := range rows {
+		stats.Daily = append(stats.Daily, DailyClicks{row.Date.Format(dateLayout), row.Clicks, row.UniqueVisitors})
+	}
+	return stats, nil
+}
+
+func (r *gormRepository) SoftDelete(code string) error {
+	result := r.db.Model(&models.Url{}).Where("short_code = ?", code).Updates(map[string]interface{}{
+		"is_deleted": true,
+		"deleted_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected <= 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormRepository) Restore(code string) error {
+	result := r.db.Model(&models.Url{}).Where("short_code = ? AND is_deleted = ?", code, true).Updates(map[string]interface{}{
+		"is_deleted": false,
+		"deleted_at": nil,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected <= 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormRepository) MarkExpired(now time.Time) (int64, error) {
+	result := r.db.Model(&models.Url{}).
+		Where("status = ? AND expiry_date <= ?", models.StatusActive, now).
+		Update("status", models.StatusExpired)
+	return result.RowsAffected, result.Error
+}
+
+func (r *gormRepository) ScanUrls(after string, limit int) ([]models.Url, error) {
+	urls := []models.Url{}
+	result := r.db.Where("is_deleted = ? AND threat_feed = ? AND short_code > ?", false, "", after).
+		Order("short_code").Limit(limit).Find(&urls)
+	return urls, result.Error
+}
+
+func (r *gormRepository) FlagThreat(code, feed string, disable bool, at time.Time) error {
+	values := map[string]interface{}{
+		"threat_feed": feed,
+		"flagged_at":  at,
+	}
+	if disable {
+		values["is_disabled"] = true
+	}
+	result := r.db.Model(&models.Url{}).Where("short_code = ?", code).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected <= 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// purgeBatchSize number of urls removed in a transaction
+const purgeBatchSize = 1000
+
+func (r *gormRepository) Purge(before time.Time) (int64, error) {
+	var purged int64
+	for {
+		var codes []string
+		result := r.db.Model(&models.Url{}).
+			Where("(is_deleted = ? AND deleted_at < ?) OR expiry_date < ?", true, before, before).
+			Limit(purgeBatchSize).
+			Pluck("short_code", &codes)
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		if len(codes) == 0 {
+			return purged, nil
+		}
+
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("short_code IN ?", codes).Delete(&models.Click{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("short_code IN ?", codes).Delete(&models.UrlHistory{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("short_code IN ?", codes).Delete(&models.Url{}).Error
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged += int64(len(codes))
+
+		if len(codes) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func (r *gormRepository) Search(query SearchQuery) ([]models.Url, error) {
+	urls := []models.Url{}
+
+	// init chain orm
+	tx := r.filter(query)
+
+	direction, operator := "ASC", ">"
+	if query.Desc {
+		direction, operator = "DESC", "<"
+	}
+	if query.After != nil {
+		condition, values := afterCondition(query.Sort, operator, *query.After)
+		tx = tx.Where(condition, values...)
+	}
+
+	var order []string
+	for _, column := range append(orderColumns(query.Sort), "short_code") {
+		order = append(order, column+" "+direction)
+	}
+	result := tx.Order(strings.Join(order, ", ")).Limit(query.Limit).Find(&urls)
+	return urls, result.Error
+}
+
+func (r *gormRepository) Count(query SearchQuery) (int64, error) {
+	var total int64
+	result := r.filter(query).Count(&total)
+	return total, result.Error
+}
+
+// orderColumns return sql of sort columns before short_code, url without expiry date is sorted after every expiry date,
+// expiry_date is compared as column so that its index can be used
+func orderColumns(sort string) []string {
+	switch sort {
+	case SortHits:
+		return []string{"hits"}
+	case SortExpiryDate:
+		return []string{"expiry_date IS NULL", "expiry_date"}
+	default:
+		return []string{"created_at"}
+	}
+}
+
+// afterCondition return sql condition of urls after cursor in sort order, operator is > ascending and < descending
+func afterCondition(sort, operator string, after Cursor) (string, []interface{}) {
+	column, value := "created_at", interface{}(after.Time)
+	switch sort {
+	case SortHits:
+		column, value = "hits", after.Hits
+	case SortExpiryDate:
+		ascending := operator == ">"
+		switch {
+		case after.NoExpiry && ascending:
+			return "expiry_date IS NULL AND short_code > ?", []interface{}{after.ShortCode}
+		case after.NoExpiry:
+			return "expiry_date IS NOT NULL OR short_code < ?", []interface{}{after.ShortCode}
+		case ascending:
+			return "expiry_date IS NULL OR expiry_date > ? OR (expiry_date = ? AND short_code > ?)",
+				[]interface{}{after.Time, after.Time, after.ShortCode}
+		}
+		column = "expiry_date"
+	}
+	return fmt.Sprintf("%s %s ? OR (%s = ? AND short_code %s ?)", column, operator, column, operator),
+		[]interface{}{value, value, after.ShortCode}
+}
+
+// filter chain conditions of query filters
+func (r *gormRepository) filter(query SearchQuery) *gorm.DB {
+	tx := r.db.Model(&models.Url{})
+	if query.Keyword != "" {
+		tx = tx.Where("full_url LIKE ?", "%"+query.Keyword+"%")
+	}
+	if query.Owner != "" {
+		tx = tx.Where("owner = ?", query.Owner)
+	}
+	if query.Deleted != nil {
+		tx = tx.Where("is_deleted = ?", *query.Deleted)
+	}
+	if query.Expired != nil {
+		if *query.Expired {
+			tx = tx.Where("status = ? OR expiry_date <= ?", models.StatusExpired, query.Now)
+		} else {
+			tx = tx.Where("status <> ? AND (expiry_date IS NULL OR expiry_date > ?)", models.StatusExpired, query.Now)
+		}
+	}
+	if query.ExpiryFrom != nil {
+		tx = tx.Where("expiry_date >= ?", *query.ExpiryFrom)
+	}
+	if query.ExpiryTo != nil {
+		tx = tx.Where("expiry_date <= ?", *query.ExpiryTo)
+	}
+	if query.MinHits > 0 {
+		tx = tx.Where("hits >= ?", query.MinHits)
+	}
+	if query.Scheduled != nil {
+		if *query.Scheduled {
+			tx = tx.Where("starts_at > ?", query.Now)
+		} else {
+			tx = tx.Where("starts_at IS NULL OR starts_at <= ?", query.Now)
+		}
+	}
+	if query.Flagged != nil {
+		if *query.Flagged {
+			tx = tx.Where("threat_feed <> ?", "")
+		} else {
+			tx = tx.Where("threat_feed = ?", "")
+		}
+	}
+	return tx
+}
@@ -0,0 +1,115 @@
+package url
+
+import (
+	"log"
+	"rabbit-shorten-url/internal/url/models"
+	"sync"
+	"time"
+)
+
+const (
+	// clickBatchSize max click events written in one insert
+	clickBatchSize = 500
+	// maxPendingClicks limit buffered click events while repository is unavailable, later clicks are dropped
+	maxPendingClicks = 100000
+)
+
+// HitCounter buffer hits per short_code and click events in memory and flush them to repository in batches
+type HitCounter struct {
+	repo     Repository
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]int
+	clicks  []models.Click
+	dropped int
+
+	worker worker
+}
+
+// NewHitCounter initial hit counter which flush to repo every interval after Start
+func NewHitCounter(repo Repository, interval time.Duration) *HitCounter {
+	return &HitCounter{
+		repo:     repo,
+		interval: interval,
+		pending:  map[string]int{},
+		worker:   newWorker(),
+	}
+}
+
+// Add buffer n hits of short_code
+func (h *HitCounter) Add(code string, n int) {
+	h.mu.Lock()
+	h.pending[code] += n
+	h.mu.Unlock()
+}
+
+// AddClick buffer click event of redirect
+func (h *HitCounter) AddClick(click models.Click) {
+	h.mu.Lock()
+	h.addClicks([]models.Click{click})
+	h.mu.Unlock()
+}
+
+// addClicks append clicks within maxPendingClicks, caller must hold mu
+func (h *HitCounter) addClicks(clicks []models.Click) {
+	if room := maxPendingClicks - len(h.clicks); len(clicks) > room {
+		h.dropped += len(clicks) - room
+		clicks = clicks[:room]
+	}
+	h.clicks = append(h.clicks, clicks...)
+}
+
+// Flush write buffered hits with atomic increment and clicks in batches, failed ones are kept for next flush
+func (h *HitCounter) Flush() error {
+	h.mu.Lock()
+	batch := h.pending
+	h.pending = map[string]int{}
+	clicks := h.clicks
+	h.clicks = nil
+	dropped := h.dropped
+	h.dropped = 0
+	h.mu.Unlock()
+
+	if dropped > 0 {
+		log.Printf("dropped %d clicks while buffer was full", dropped)
+	}
+
+	var lastErr error
+	for code, n := range batch {
+		if err := h.repo.IncrementHits(code, n); err != nil {
+			lastErr = err
+			h.Add(code, n)
+		}
+	}
+	for len(clicks) > 0 {
+		n := clickBatchSize
+		if n > len(clicks) {
+			n = len(clicks)
+		}
+		if err := h.repo.InsertClicks(clicks[:n]); err != nil {
+			lastErr = err
+			h.mu.Lock()
+			h.addClicks(clicks)
+			h.mu.Unlock()
+			break
+		}
+		clicks = clicks[n:]
+	}
+	return lastErr
+}
+
+// Start flush hits and clicks every interval in background until Stop
+func (h *HitCounter) Start() {
+	h.worker.run(h.interval, func() {
+		if err := h.Flush(); err != nil {
+			log.Printf("could not flush hits and clicks: %v", err)
+		}
+	})
+}
+
+// Stop background flush and write remaining hits and clicks, used on graceful shutdown
+func (h *HitCounter) Stop() error {
+	h.worker.stopWait()
+	return h.Flush()
+}
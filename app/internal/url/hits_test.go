@@ -0,0 +1,70 @@
+package url
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"net/http/httptest"
+	"rabbit-shorten-url/internal/url/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHitCounter(t *testing.T) {
+	repo := NewMemoryRepository()
+	if err := repo.Insert(&models.Url{ShortCode: "test1234", Hits: 2}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	h := NewHitCounter(repo, time.Hour)
+	h.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Add("test1234", 1)
+		}()
+	}
+	wg.Wait()
+
+	url, _ := repo.GetByCode("test1234")
+	if url.Hits != 2 {
+		t.Errorf("Hits = %v before flush, want 2", url.Hits)
+	}
+
+	if err := h.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	url, _ = repo.GetByCode("test1234")
+	if url.Hits != 102 {
+		t.Errorf("Hits = %v after stop, want 102", url.Hits)
+	}
+}
+
+func TestHitCounter_Clicks(t *testing.T) {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{HitCounter: NewHitCounter(repo, time.Hour)})
+	if err := repo.Insert(&models.Url{ShortCode: "test1234", FullUrl: "https://docs.gofiber.io/"}); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	app := fiber.New()
+	app.Get("/:code", u.Redirect)
+
+	for i := 0; i < clickBatchSize+1; i++ {
+		if res, _ := app.Test(httptest.NewRequest("GET", "/test1234", nil), -1); res.StatusCode != fiber.StatusFound {
+			t.Fatalf("Redirect() status = %v", res.StatusCode)
+		}
+	}
+
+	since := time.Now().AddDate(0, 0, -1)
+	if stats, _ := repo.GetClickStats("test1234", since); stats.TotalClicks != 0 {
+		t.Errorf("TotalClicks = %v before flush, want 0", stats.TotalClicks)
+	}
+	if err := u.config.HitCounter.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if stats, _ := repo.GetClickStats("test1234", since); stats.TotalClicks != clickBatchSize+1 {
+		t.Errorf("TotalClicks = %v after flush, want %v", stats.TotalClicks, clickBatchSize+1)
+	}
+}
@@ -0,0 +1,436 @@
+package url
+
+import (
+	"rabbit-shorten-url/internal/url/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryRepository struct {
+	mu        sync.RWMutex
+	urls      map[string]models.Url
+	clicks    []models.Click
+	histories []models.UrlHistory
+
+	blockRules      []models.BlockRule
+	lastBlockRuleID uint
+}
+
+// NewMemoryRepository initial in-memory url repository, used for tests and local development
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		urls: map[string]models.Url{},
+	}
+}
+
+func (r *memoryRepository) ListBlockRules() ([]models.BlockRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.BlockRule{}, r.blockRules...), nil
+}
+
+func (r *memoryRepository) InsertBlockRule(rule *models.BlockRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastBlockRuleID++
+	rule.ID = r.lastBlockRuleID
+	rule.CreatedAt = time.Now()
+	r.blockRules = append(r.blockRules, *rule)
+	return nil
+}
+
+func (r *memoryRepository) DeleteBlockRule(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, rule := range r.blockRules {
+		if rule.ID == id {
+			r.blockRules = append(r.blockRules[:i], r.blockRules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryRepository) GetByCode(code string) (models.Url, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	url, ok := r.urls[code]
+	if !ok {
+		return models.Url{}, ErrNotFound
+	}
+	return url, nil
+}
+
+func (r *memoryRepository) GetReusable(query ReuseQuery) (models.Url, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, url := range r.urls {
+		switch {
+		case url.UrlHash != query.UrlHash, url.IsDeleted, url.IsDisabled, url.Status == models.StatusExpired,
+			url.PasswordHash != "", url.MaxHits != 0, url.StartsAt != nil,
+			!containsInt(query.RedirectTypes, url.RedirectType),
+			url.Owner != query.Owner,
+			(url.ApiKeyID == nil) != (query.ApiKeyID == nil),
+			url.ApiKeyID != nil && *url.ApiKeyID != *query.ApiKeyID,
+			(url.ExpiryDate == nil) != (query.ExpiryDate == nil),
+			url.ExpiryDate != nil && !url.ExpiryDate.Equal(*query.ExpiryDate):
+			continue
+		}
+		return url, nil
+	}
+	return models.Url{}, ErrNotFound
+}
+
+// containsInt report whether values contains v
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryRepository) Insert(url *models.Url) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.urls[url.ShortCode]; ok {
+		return ErrDuplicated
+	}
+	if url.CreatedAt.IsZero() {
+		url.CreatedAt = time.Now()
+	}
+	r.urls[url.ShortCode] = *url
+	return nil
+}
+
+func (r *memoryRepository) IncrementHits(code string, n int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.urls[code]
+	if !ok {
+		return nil
+	}
+	url.Hits += n
+	r.urls[code] = url
+	return nil
+}
+
+func (r *memoryRepository) ConsumeHit(code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.urls[code]
+	if !ok || (url.MaxHits > 0 && url.Hits >= url.MaxHits) {
+		return ErrExhausted
+	}
+	url.Hits++
+	r.urls[code] = url
+	return nil
+}
+
+func (r *memoryRepository) Update(url *models.Url, columns []string, history *models.UrlHistory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.urls[url.ShortCode]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, column := range columns {
+		switch column {
+		case "full_url":
+			stored.FullUrl = url.FullUrl
+		case "url_hash":
+			stored.UrlHash = url.UrlHash
+		case "expiry_date":
+			stored.ExpiryDate = url.ExpiryDate
+		case "redirect_type":
+			stored.RedirectType = url.RedirectType
+		case "status":
+			stored.Status = url.Status
+		case "threat_feed":
+			stored.ThreatFeed = url.ThreatFeed
+		case "flagged_at":
+			stored.FlaggedAt = url.FlaggedAt
+		case "is_disabled":
+			stored.IsDisabled = url.IsDisabled
+		}
+	}
+	r.urls[url.ShortCode] = stored
+
+	history.ID = uint(len(r.histories) + 1)
+	r.histories = append(r.histories, *history)
+	return nil
+}
+
+func (r *memoryRepository) GetHistory(code string) ([]models.UrlHistory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	histories := []models.UrlHistory{}
+	for i := len(r.histories) - 1; i >= 0; i-- {
+		if r.histories[i].ShortCode == code {
+			histories = append(histories, r.histories[i])
+		}
+	}
+	return histories, nil
+}
+
+func (r *memoryRepository) InsertClick(click *models.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	click.ID = uint(len(r.clicks) + 1)
+	r.clicks = append(r.clicks, *click)
+	return nil
+}
+
+func (r *memoryRepository) InsertClicks(clicks []models.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, click := range clicks {
+		click.ID = uint(len(r.clicks) + 1)
+		r.clicks = append(r.clicks, click)
+	}
+	return nil
+}
+
+func (r *memoryRepository) GetClickStats(code string, since time.Time) (ClickStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := ClickStats{Daily: []DailyClicks{}}
+	visitors := map[string]bool{}
+	dailyVisitors := map[string]map[string]bool{}
+	daily := map[string]int64{}
+	for _, click := range r.clicks {
+		if click.ShortCode != code {
+			continue
+		}
+		stats.TotalClicks++
+		visitors[click.IpHash] = true
+
+		if click.ClickedAt.Before(since) {
+			continue
+		}
+		date := click.ClickedAt.Format(dateLayout)
+		if dailyVisitors[date] == nil {
+			dailyVisitors[date] = map[string]bool{}
+		}
+		dailyVisitors[date][click.IpHash] = true
+		daily[date]++
+	}
+	stats.UniqueVisitors = int64(len(visitors))
+
+	for date, clicks := range daily {
+		stats.Daily = append(stats.Daily, DailyClicks{date, clicks, int64(len(dailyVisitors[date]))})
+	}
+	sort.Slice(stats.Daily, func(i, j int) bool {
+		return stats.Daily[i].Date < stats.Daily[j].Date
+	})
+	return stats, nil
+}
+
+func (r *memoryRepository) SoftDelete(code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.urls[code]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	url.IsDeleted = true
+	url.DeletedAt = &now
+	r.urls[code] = url
+	return nil
+}
+
+func (r *memoryRepository) Restore(code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.urls[code]
+	if !ok || !url.IsDeleted {
+		return ErrNotFound
+	}
+	url.IsDeleted = false
+	url.DeletedAt = nil
+	r.urls[code] = url
+	return nil
+}
+
+func (r *memoryRepository) MarkExpired(now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var processed int64
+	for code, url := range r.urls {
+		if url.Status == models.StatusActive && url.ExpiryDate != nil && !url.ExpiryDate.After(now) {
+			url.Status = models.StatusExpired
+			r.urls[code] = url
+			processed++
+		}
+	}
+	return processed, nil
+}
+
+func (r *memoryRepository) ScanUrls(after string, limit int) ([]models.Url, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	urls := []models.Url{}
+	for _, url := range r.urls {
+		if !url.IsDeleted && url.ThreatFeed == "" && url.ShortCode > after {
+			urls = append(urls, url)
+		}
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		return urls[i].ShortCode < urls[j].ShortCode
+	})
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+	return urls, nil
+}
+
+func (r *memoryRepository) FlagThreat(code, feed string, disable bool, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	url, ok := r.urls[code]
+	if !ok {
+		return ErrNotFound
+	}
+	url.ThreatFeed = feed
+	url.FlaggedAt = &at
+	if disable {
+		url.IsDisabled = true
+	}
+	r.urls[code] = url
+	return nil
+}
+
+func (r *memoryRepository) Purge(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := map[string]bool{}
+	for code, url := range r.urls {
+		if (url.IsDeleted && url.DeletedAt != nil && url.DeletedAt.Before(before)) ||
+			(url.ExpiryDate != nil && url.ExpiryDate.Before(before)) {
+			purged[code] = true
+			delete(r.urls, code)
+		}
+	}
+
+	clicks := r.clicks[:0]
+	for _, click := range r.clicks {
+		if !purged[click.ShortCode] {
+			clicks = append(clicks, click)
+		}
+	}
+	r.clicks = clicks
+
+	histories := r.histories[:0]
+	for _, history := range r.histories {
+		if !purged[history.ShortCode] {
+			histories = append(histories, history)
+		}
+	}
+	r.histories = histories
+
+	return int64(len(purged)), nil
+}
+
+func (r *memoryRepository) Search(query SearchQuery) ([]models.Url, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	urls := []models.Url{}
+	for _, url := range r.urls {
+		if !match(url, query) {
+			continue
+		}
+		if query.After != nil && !after(sortValue(url, query.Sort), *query.After, query.Desc) {
+			continue
+		}
+		urls = append(urls, url)
+	}
+
+	sort.Slice(urls, func(i, j int) bool {
+		return after(sortValue(urls[j], query.Sort), sortValue(urls[i], query.Sort), query.Desc)
+	})
+	if query.Limit > 0 && len(urls) > query.Limit {
+		urls = urls[:query.Limit]
+	}
+	return urls, nil
+}
+
+func (r *memoryRepository) Count(query SearchQuery) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var total int64
+	for _, url := range r.urls {
+		if match(url, query) {
+			total++
+		}
+	}
+	return total, nil
+}
+
+// match report whether url matches filters of query
+func match(url models.Url, query SearchQuery) bool {
+	expired := url.Status == models.StatusExpired || (url.ExpiryDate != nil && !url.ExpiryDate.After(query.Now))
+	scheduled := url.StartsAt != nil && url.StartsAt.After(query.Now)
+	switch {
+	case !strings.Contains(url.FullUrl, query.Keyword),
+		query.Owner != "" && url.Owner != query.Owner,
+		query.Deleted != nil && url.IsDeleted != *query.Deleted,
+		query.Expired != nil && expired != *query.Expired,
+		query.ExpiryFrom != nil && (url.ExpiryDate == nil || url.ExpiryDate.Before(*query.ExpiryFrom)),
+		query.ExpiryTo != nil && (url.ExpiryDate == nil || url.ExpiryDate.After(*query.ExpiryTo)),
+		url.Hits < query.MinHits,
+		query.Flagged != nil && (url.ThreatFeed != "") != *query.Flagged,
+		query.Scheduled != nil && scheduled != *query.Scheduled:
+		return false
+	}
+	return true
+}
+
+// after report whether sort value a comes after cursor b in sort order
+func after(a, b Cursor, desc bool) bool {
+	var cmp int
+	switch {
+	case a.Hits != b.Hits:
+		cmp = a.Hits - b.Hits
+	case a.NoExpiry != b.NoExpiry:
+		cmp = -1
+		if a.NoExpiry {
+			cmp = 1
+		}
+	case !a.Time.Equal(b.Time):
+		cmp = 1
+		if a.Time.Before(b.Time) {
+			cmp = -1
+		}
+	default:
+		cmp = strings.Compare(a.ShortCode, b.ShortCode)
+	}
+	if desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
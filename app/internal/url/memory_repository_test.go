@@ -0,0 +1,65 @@
+package url
+
+import (
+	"errors"
+	"rabbit-shorten-url/internal/url/models"
+	"testing"
+)
+
+func Test_memoryRepository(t *testing.T) {
+	repo := NewMemoryRepository()
+	for _, url := range []models.Url{
+		{ShortCode: "test1234", FullUrl: "https://www.google.com"},
+		{ShortCode: "test5678", FullUrl: "https://docs.gofiber.io/"},
+	} {
+		url := url
+		if err := repo.Insert(&url); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	if err := repo.Insert(&models.Url{ShortCode: "test1234"}); !errors.Is(err, ErrDuplicated) {
+		t.Errorf("Insert() error = %v, want %v", err, ErrDuplicated)
+	}
+	if _, err := repo.GetByCode("notfound"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByCode() error = %v, want %v", err, ErrNotFound)
+	}
+	if err := repo.SoftDelete("notfound"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SoftDelete() error = %v, want %v", err, ErrNotFound)
+	}
+
+	if err := repo.IncrementHits("test1234", 3); err != nil {
+		t.Fatalf("IncrementHits() error = %v", err)
+	}
+	if err := repo.SoftDelete("test1234"); err != nil {
+		t.Fatalf("SoftDelete() error = %v", err)
+	}
+	url, err := repo.GetByCode("test1234")
+	if err != nil {
+		t.Fatalf("GetByCode() error = %v", err)
+	}
+	if url.Hits != 3 || !url.IsDeleted {
+		t.Errorf("GetByCode() = %+v, want hits 3 and deleted", url)
+	}
+
+	tests := []struct {
+		name    string
+		keyword string
+		want    int
+	}{
+		{"should list all", "", 2},
+		{"should list by keyword", "google", 1},
+		{"should list nothing", "facebook", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.Search(SearchQuery{Keyword: tt.keyword, Sort: SortCreatedAt})
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("Search() = %v, want %v urls", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// kind of block rule
+const (
+	BlockDomain = "domain"
+	BlockSuffix = "suffix"
+	BlockRegex  = "regex"
+)
+
+type BlockRule struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Kind      string    `json:"kind"`
+	Pattern   string    `json:"pattern"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Click struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ShortCode      string    `gorm:"index" json:"short_code"`
+	ClickedAt      time.Time `json:"clicked_at"`
+	Referrer       string    `json:"referrer"`
+	UserAgent      string    `json:"user_agent"`
+	IpHash         string    `json:"ip_hash"`
+	AcceptLanguage string    `json:"accept_language"`
+}
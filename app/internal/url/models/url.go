@@ -2,10 +2,38 @@ package models
 
 import "time"
 
+// status of url, expired is marked by expiry sweeper
+const (
+	StatusActive  = "active"
+	StatusExpired = "expired"
+)
+
+// state of url at a time, it is computed for listing and not stored
+const (
+	StateScheduled = "scheduled"
+	StateActive    = "active"
+	StateExpired   = "expired"
+)
+
 type Url struct {
-	ShortCode  string     `gorm:"primaryKey" json:"short_code"`
-	FullUrl    string     `json:"full_url"`
-	ExpiryDate *time.Time `json:"expiry_date"`
-	Hits       int        `json:"hits"`
-	IsDeleted  bool       `json:"is_deleted"`
+	ShortCode    string     `gorm:"primaryKey" json:"short_code"`
+	FullUrl      string     `json:"full_url"`
+	UrlHash      string     `gorm:"index" json:"-"`
+	StartsAt     *time.Time `json:"starts_at"`
+	ExpiryDate   *time.Time `json:"expiry_date"`
+	Hits         int        `json:"hits"`
+	MaxHits      int        `json:"max_hits"`
+	IsDeleted    bool       `json:"is_deleted"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+	RedirectType int        `json:"redirect_type"`
+	Status       string     `json:"status"`
+	State        string     `gorm:"-" json:"state,omitempty"`
+	ApiKeyID     *uint      `gorm:"index" json:"api_key_id"`
+	Owner        string     `gorm:"index" json:"owner"`
+	ThreatFeed   string     `json:"threat_feed"`
+	FlaggedAt    *time.Time `json:"flagged_at"`
+	IsDisabled   bool       `json:"is_disabled"`
+	PasswordHash string     `json:"-"`
+	FallbackUrl  string     `json:"fallback_url"`
+	CreatedAt    time.Time  `json:"created_at"`
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type UrlHistory struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	ShortCode    string     `gorm:"index" json:"short_code"`
+	FullUrl      string     `json:"full_url"`
+	ExpiryDate   *time.Time `json:"expiry_date"`
+	RedirectType int        `json:"redirect_type"`
+	ChangedAt    time.Time  `json:"changed_at"`
+}
@@ -0,0 +1,30 @@
+package url
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"rabbit-shorten-url/internal/auth"
+)
+
+// MyUrls is used to list urls created by the caller, accepts the same query as List
+func (u *service) MyUrls(c *fiber.Ctx) error {
+	query, err := parseSearchQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+	}
+	query.Owner = auth.Owner(c)
+
+	return u.list(c, query)
+}
+
+// DeleteOwn is used to soft delete url created by the caller, url of other owner is not found
+func (u *service) DeleteOwn(c *fiber.Ctx) error {
+	url, err := u.repo.GetByCode(c.Params("code"))
+	if errors.Is(err, ErrNotFound) || (err == nil && (url.Owner == "" || url.Owner != auth.Owner(c))) {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	return u.SoftDelete(c)
+}
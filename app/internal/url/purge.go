@@ -0,0 +1,77 @@
+package url
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"time"
+)
+
+// PurgeResponse return number of purged urls
+type PurgeResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+// Purger permanently remove urls which have been deleted or expired for longer than retention
+type Purger struct {
+	repo      Repository
+	retention time.Duration
+	interval  time.Duration
+
+	worker worker
+}
+
+// NewPurger initial purger which purge every interval after Start
+func NewPurger(repo Repository, retention, interval time.Duration) *Purger {
+	return &Purger{
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+		worker:    newWorker(),
+	}
+}
+
+// Purge remove urls deleted or expired before retention with their clicks and history
+func (p *Purger) Purge() (int64, error) {
+	return p.repo.Purge(time.Now().Add(-p.retention))
+}
+
+// Start purge every interval in background until Stop
+func (p *Purger) Start() {
+	p.worker.run(p.interval, func() {
+		purged, err := p.Purge()
+		if err != nil {
+			log.Printf("could not purge urls: %v", err)
+			return
+		}
+		if purged > 0 {
+			log.Printf("purged %d urls", purged)
+		}
+	})
+}
+
+// Stop background purge
+func (p *Purger) Stop() {
+	p.worker.stopWait()
+}
+
+// Purge is used to permanently remove urls deleted or expired for longer than retention query (Go duration),
+// default is Config.PurgeRetention
+func (u *service) Purge(c *fiber.Ctx) error {
+	retention := u.config.PurgeRetention
+	if value := c.Query("retention"); value != "" {
+		var err error
+		if retention, err = time.ParseDuration(value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+		}
+	}
+	if retention <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{ErrInvalidRetention.Error()})
+	}
+
+	purged, err := u.repo.Purge(time.Now().Add(-retention))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	return c.JSON(PurgeResponse{purged})
+}
@@ -0,0 +1,65 @@
+package url
+
+import (
+	"rabbit-shorten-url/internal/url/models"
+	"time"
+)
+
+// Repository interface for url storage, implemented by gorm (mysql) and in-memory backends
+type Repository interface {
+	// GetByCode return url by short_code or ErrNotFound
+	GetByCode(code string) (models.Url, error)
+	// GetReusable return active url matching query which can be returned instead of creating the same url or ErrNotFound
+	GetReusable(query ReuseQuery) (models.Url, error)
+	// Insert store new url
+	Insert(url *models.Url) error
+	// IncrementHits atomically add n to hits of url by short_code
+	IncrementHits(code string, n int) error
+	// ConsumeHit atomically add one hit to url by short_code unless it reached max_hits, return ErrExhausted then
+	ConsumeHit(code string) error
+	// Update save columns of url changed by request and its previous values as history
+	Update(url *models.Url, columns []string, history *models.UrlHistory) error
+	// GetHistory list previous values of url by short_code, newest first
+	GetHistory(code string) ([]models.UrlHistory, error)
+	// SoftDelete mark flag is_deleted = true by short_code or return ErrNotFound
+	SoftDelete(code string) error
+	// Restore mark flag is_deleted = false by short_code or return ErrNotFound when it is not deleted
+	Restore(code string) error
+	// MarkExpired set status expired to active urls past their expiry date at now, return number of urls
+	MarkExpired(now time.Time) (int64, error)
+	// ScanUrls list urls which are not deleted or flagged with short_code after after, ordered by short_code
+	ScanUrls(after string, limit int) ([]models.Url, error)
+	// FlagThreat record threat feed listing host of url by short_code and disable it when disable is set
+	FlagThreat(code, feed string, disable bool, at time.Time) error
+	// Purge permanently remove urls deleted or expired before with their clicks and history, return number of urls
+	Purge(before time.Time) (int64, error)
+	// Search list a page of urls matching filters of query in its sort order
+	Search(query SearchQuery) ([]models.Url, error)
+	// Count return number of urls matching filters of query, sort and page are ignored
+	Count(query SearchQuery) (int64, error)
+	// InsertClick store click event of redirect
+	InsertClick(click *models.Click) error
+	// InsertClicks store buffered click events of redirects in one batch
+	InsertClicks(clicks []models.Click) error
+	// GetClickStats return all time totals and daily clicks since of short_code
+	GetClickStats(code string, since time.Time) (ClickStats, error)
+	// ListBlockRules list all block rules
+	ListBlockRules() ([]models.BlockRule, error)
+	// InsertBlockRule store new block rule
+	InsertBlockRule(rule *models.BlockRule) error
+	// DeleteBlockRule remove block rule by id or return ErrNotFound
+	DeleteBlockRule(id uint) error
+}
+
+// ReuseQuery attributes of created url which an existing url must have to be reused, protected, limited,
+// scheduled, disabled and expired urls are never reused
+type ReuseQuery struct {
+	UrlHash string
+	// ExpiryDate expiry date of created url, nil matches urls which never expire
+	ExpiryDate *time.Time
+	// RedirectTypes redirect types equal to redirect type of created url, 0 is default redirect type of stored url
+	RedirectTypes []int
+	// Owner and ApiKeyID creator of created url, url of another owner is never reused
+	Owner    string
+	ApiKeyID *uint
+}
@@ -0,0 +1,42 @@
+package url
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"rabbit-shorten-url/internal/url/models"
+	"time"
+)
+
+// ErrStartsAfterExpiry is the error in case of url would expire before it starts redirecting
+var ErrStartsAfterExpiry = errors.New("starts_at: must be before expiry")
+
+// validateSchedule validate starts_at and fallback_url of create request, fallback_url is replaced by its destination
+func (u *service) validateSchedule(c *fiber.Ctx, req *CreateRequest, expiryDate *time.Time) error {
+	if req.StartsAt != nil && expiryDate != nil && !req.StartsAt.Before(*expiryDate) {
+		return ErrStartsAfterExpiry
+	}
+
+	if req.FallbackUrl == "" {
+		return nil
+	}
+	if req.StartsAt == nil {
+		return errors.New("fallback_url: requires starts_at")
+	}
+	fallback, err := u.destination(c, req.FallbackUrl)
+	if err != nil {
+		return errors.New("fallback_url: " + err.Error())
+	}
+	req.FallbackUrl = fallback
+	return nil
+}
+
+// urlState return scheduled, active or expired state of url at now
+func urlState(url models.Url, now time.Time) string {
+	switch {
+	case url.Status == models.StatusExpired || (url.ExpiryDate != nil && !url.ExpiryDate.After(now)):
+		return models.StateExpired
+	case url.StartsAt != nil && now.Before(*url.StartsAt):
+		return models.StateScheduled
+	}
+	return models.StateActive
+}
@@ -0,0 +1,31 @@
+package url
+
+import (
+	"rabbit-shorten-url/internal/url/models"
+	"testing"
+	"time"
+)
+
+func Test_urlState(t *testing.T) {
+	now := time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		url  models.Url
+		want string
+	}{
+		{"should be active without schedule", models.Url{}, models.StateActive},
+		{"should be scheduled before starts_at", models.Url{StartsAt: &after}, models.StateScheduled},
+		{"should be active after starts_at", models.Url{StartsAt: &before, ExpiryDate: &after}, models.StateActive},
+		{"should be expired after expiry_date", models.Url{StartsAt: &before, ExpiryDate: &before}, models.StateExpired},
+		{"should be expired when marked", models.Url{Status: models.StatusExpired}, models.StateExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := urlState(tt.url, now); got != tt.want {
+				t.Errorf("urlState() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package url
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	neturl "net/url"
+	"rabbit-shorten-url/internal/url/models"
+	"strconv"
+	"time"
+)
+
+const (
+	SortCreatedAt  = "created_at"
+	SortHits       = "hits"
+	SortExpiryDate = "expiry_date"
+
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+var (
+	// ErrInvalidCursor is the error in case of cursor query can not be decoded
+	ErrInvalidCursor = errors.New("cursor: is not valid")
+
+	// sortColumns allowed value of sort query
+	sortColumns = []string{SortCreatedAt, SortHits, SortExpiryDate}
+)
+
+// SearchQuery filters, sort and page of url listing, nil filter is not applied
+type SearchQuery struct {
+	Keyword    string
+	Owner      string
+	Deleted    *bool
+	Expired    *bool
+	ExpiryFrom *time.Time
+	ExpiryTo   *time.Time
+	MinHits    int
+	Flagged    *bool
+	Scheduled  *bool
+	Sort       string
+	Desc       bool
+	Limit      int
+	After      *Cursor
+	Now        time.Time
+}
+
+// Cursor position after the last url of previous page
+type Cursor struct {
+	Hits int       `json:"h,omitempty"`
+	Time time.Time `json:"t,omitempty"`
+	// NoExpiry url without expiry date, it is sorted after every expiry date
+	NoExpiry  bool   `json:"n,omitempty"`
+	ShortCode string `json:"c"`
+}
+
+// ListResponse return a page of urls with total count of filtered urls and link to next page
+type ListResponse struct {
+	Data       []models.Url `json:"data"`
+	Total      int64        `json:"total"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Next       string       `json:"next,omitempty"`
+}
+
+// sortValue return value of url for sort column of query
+func sortValue(url models.Url, sort string) Cursor {
+	cursor := Cursor{ShortCode: url.ShortCode}
+	switch sort {
+	case SortHits:
+		cursor.Hits = url.Hits
+	case SortExpiryDate:
+		cursor.NoExpiry = url.ExpiryDate == nil
+		if url.ExpiryDate != nil {
+			cursor.Time = *url.ExpiryDate
+		}
+	default:
+		cursor.Time = url.CreatedAt
+	}
+	return cursor
+}
+
+// encodeCursor return opaque cursor query of url
+func encodeCursor(cursor Cursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parse cursor query created by encodeCursor
+func decodeCursor(value string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.ShortCode == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// parseSearchQuery read filters, sort and page of url listing from query string
+func parseSearchQuery(c *fiber.Ctx) (SearchQuery, error) {
+	query := SearchQuery{
+		Keyword: c.Query("full_url"),
+		Owner:   c.Query("owner"),
+		Sort:    c.Query("sort", SortCreatedAt),
+		Desc:    c.Query("order", "desc") == "desc",
+		Limit:   defaultListLimit,
+		Now:     time.Now(),
+	}
+
+	if !contains(sortColumns, query.Sort) {
+		return query, fmt.Errorf("sort: must be one of %v", sortColumns)
+	}
+	if order := c.Query("order", "desc"); order != "asc" && order != "desc" {
+		return query, errors.New("order: must be asc or desc")
+	}
+
+	for _, filter := range []struct {
+		name  string
+		value **bool
+	}{
+		{"deleted", &query.Deleted},
+		{"expired", &query.Expired},
+		{"flagged", &query.Flagged},
+		{"scheduled", &query.Scheduled},
+	} {
+		if value := c.Query(filter.name); value != "" {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return query, fmt.Errorf("%s: must be true or false", filter.name)
+			}
+			*filter.value = &b
+		}
+	}
+
+	for _, filter := range []struct {
+		name  string
+		value **time.Time
+	}{
+		{"expiry_from", &query.ExpiryFrom},
+		{"expiry_to", &query.ExpiryTo},
+	} {
+		if value := c.Query(filter.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, fmt.Errorf("%s: must be RFC 3339 time", filter.name)
+			}
+			*filter.value = &t
+		}
+	}
+
+	if value := c.Query("min_hits"); value != "" {
+		minHits, err := strconv.Atoi(value)
+		if err != nil || minHits < 0 {
+			return query, errors.New("min_hits: must be zero or positive integer")
+		}
+		query.MinHits = minHits
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return query, fmt.Errorf("limit: must be between 1 and %d", maxListLimit)
+		}
+		query.Limit = limit
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil {
+			return query, err
+		}
+		query.After = cursor
+	}
+
+	return query, nil
+}
+
+// nextLink return url of current request with cursor replaced by next cursor
+func nextLink(c *fiber.Ctx, cursor string) string {
+	values, _ := neturl.ParseQuery(string(c.Request().URI().QueryString()))
+	values.Set("cursor", cursor)
+	return c.BaseURL() + c.Path() + "?" + values.Encode()
+}
+
+// contains report whether value is in values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,39 +1,98 @@
 package url
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"math/rand"
+	"github.com/gofiber/fiber/v2"
+	neturl "net/url"
 	"regexp"
 	"strings"
-	"time"
 )
 
-const regExBlockList = "(?:facebook)"
+const (
+	aliasMinLength = 3
+	aliasMaxLength = 32
+	// maxGenerateAttempts limit generation of short_code when generated code is already used
+	maxGenerateAttempts = 10
+)
 
 var (
-	// ErrURLBlockList is the error in case of url is match with regExBlockList
+	// ErrURLBlockList is the error in case of host of url matches a rule of BlockList
 	ErrURLBlockList = errors.New("url is not allowed")
+	// ErrURLNotAbsolute is the error in case of url has no http or https scheme or no host
+	ErrURLNotAbsolute = errors.New("url must be an absolute http or https url")
+	// ErrAliasReserved is the error in case of alias is one of reservedAliases
+	ErrAliasReserved = errors.New("alias is reserved")
+
+	// regExAlias allowed characters of custom alias
+	regExAlias = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+	// reservedAliases words which conflict with routes of the service
+	reservedAliases = []string{"admin"}
+	// RedirectTypes allowed status of redirect, 301 and 308 are permanent, 302 and 307 are temporary
+	RedirectTypes = []interface{}{
+		fiber.StatusMovedPermanently,
+		fiber.StatusFound,
+		fiber.StatusTemporaryRedirect,
+		fiber.StatusPermanentRedirect,
+	}
 )
 
-// checkBlockList custom rule for block list validation
-func checkBlockList(value interface{}) error {
+// normalizeUrl lower scheme and host, strip default port and fragment so identical urls share the same form
+func normalizeUrl(raw string) string {
+	u, err := neturl.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// urlHost return lower host of url without trailing dot, url without scheme is parsed as http url
+// so rules matching host are not skipped by stored or unvalidated urls like "facebook.com/x"
+func urlHost(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// checkAbsoluteUrl custom rule for http or https url with host, host rules can not match url without them
+func checkAbsoluteUrl(value interface{}) error {
 	s, _ := value.(string)
-	match, _ := regexp.MatchString(regExBlockList, s)
-	if match {
-		return ErrURLBlockList
+	u, err := neturl.Parse(strings.TrimSpace(s))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrURLNotAbsolute
 	}
 	return nil
 }
 
-// generateRandomString: Helper function to create random string for shorten service
-func generateRandomString(length int) string {
-	rand.Seed(time.Now().UnixNano())
-	chars := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
-		"abcdefghijklmnopqrstuvwxyz")
+// hashUrl return sha256 hex of normalized url, used to look up identical url
+func hashUrl(raw string) string {
+	sum := sha256.Sum256([]byte(normalizeUrl(raw)))
+	return hex.EncodeToString(sum[:])
+}
 
-	var b strings.Builder
-	for i := 0; i < length; i++ {
-		b.WriteRune(chars[rand.Intn(len(chars))])
+// checkReservedAlias custom rule for reserved alias validation
+func checkReservedAlias(value interface{}) error {
+	s, _ := value.(string)
+	for _, reserved := range reservedAliases {
+		if strings.EqualFold(s, reserved) {
+			return ErrAliasReserved
+		}
 	}
-	return b.String()
+	return nil
 }
@@ -2,7 +2,7 @@ package url
 
 import "testing"
 
-func Test_checkBlockList(t *testing.T) {
+func Test_checkReservedAlias(t *testing.T) {
 	type args struct {
 		value interface{}
 	}
@@ -13,27 +13,32 @@ func Test_checkBlockList(t *testing.T) {
 	}{
 		{
 			"should return error",
-			args{value: "https://www.facebook.com/"},
+			args{value: "admin"},
+			true,
+		},
+		{
+			"should return error on different case",
+			args{value: "Admin"},
 			true,
 		},
 		{
 			"should not return error",
-			args{value: "https://www.google.com/"},
+			args{value: "spring-sale"},
 			false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := checkBlockList(tt.args.value); (err != nil) != tt.wantErr {
-				t.Errorf("checkBlockList() error = %v, wantErr %v", err, tt.wantErr)
+			if err := checkReservedAlias(tt.args.value); (err != nil) != tt.wantErr {
+				t.Errorf("checkReservedAlias() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
-func Test_generateRandomString(t *testing.T) {
+func Test_normalizeUrl(t *testing.T) {
 	type args struct {
-		length int
+		raw string
 	}
 	tests := []struct {
 		name string
@@ -41,20 +46,66 @@ func Test_generateRandomString(t *testing.T) {
 		want string
 	}{
 		{
-			"should contains 8 random character",
-			args{length: 8},
-			"12345678",
+			"should lower scheme and host",
+			args{raw: "HTTPS://WWW.Google.com/Search"},
+			"https://www.google.com/Search",
+		},
+		{
+			"should strip default port and fragment",
+			args{raw: "https://www.google.com:443/#top"},
+			"https://www.google.com/",
 		},
 		{
-			"should contains 4 random character",
-			args{length: 4},
-			"1234",
+			"should add root path",
+			args{raw: "http://www.google.com"},
+			"http://www.google.com/",
 		},
+		{
+			"should keep other port and query",
+			args{raw: "http://www.google.com:8080/?q=1"},
+			"http://www.google.com:8080/?q=1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeUrl(tt.args.raw); got != tt.want {
+				t.Errorf("normalizeUrl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_hashIp(t *testing.T) {
+	u := New(NewMemoryRepository(), Config{IpHashSalt: "secret"})
+	other := New(NewMemoryRepository(), Config{IpHashSalt: "other"})
+
+	hash := u.hashIp("192.0.2.1")
+	if len(hash) != 64 || hash != u.hashIp("192.0.2.1") {
+		t.Errorf("hashIp() = %v, want stable sha256 hex", hash)
+	}
+	if hash == u.hashIp("192.0.2.2") || hash == other.hashIp("192.0.2.1") {
+		t.Errorf("hashIp() = %v for another ip or salt, want another hash", hash)
+	}
+}
+
+func Test_truncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		length int
+		want   string
+	}{
+		{"should keep short value", "abc", 5, "abc"},
+		{"should cut ascii value", "abcdef", 3, "abc"},
+		{"should cut before split rune", "aé", 2, "a"},
+		{"should keep whole rune", "aé", 3, "aé"},
+		{"should cut before 4 bytes rune", "ab😀", 5, "ab"},
+		{"should drop invalid utf-8", "a\xffb", 5, "ab"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := generateRandomString(tt.args.length); len(got) != len(tt.want) {
-				t.Errorf("generateRandomString() = %v, want %v", got, tt.want)
+			if got := truncate(tt.value, tt.length); got != tt.want {
+				t.Errorf("truncate() = %q, want %q", got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,152 @@
+package url
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// policy of urls pointing to this service or other url shorteners
+const (
+	PolicyReject  = "reject"
+	PolicyResolve = "resolve"
+
+	// maxResolveHops limit redirects followed to find final destination
+	maxResolveHops = 5
+	// resolveTimeout timeout of request to shortener domain
+	resolveTimeout = 5 * time.Second
+)
+
+var (
+	ErrURLShortener = errors.New("url points to a url shortener")
+	ErrRedirectLoop = errors.New("url redirects in a loop or too many times")
+
+	// ShortenerPolicies allowed policies of shortener urls
+	ShortenerPolicies = []interface{}{PolicyReject, PolicyResolve}
+	// DefaultShortenerDomains well known url shortener domains
+	DefaultShortenerDomains = []string{
+		"bit.ly", "bitly.com", "buff.ly", "cutt.ly", "goo.gl", "is.gd", "ow.ly",
+		"rb.gy", "rebrand.ly", "s.id", "shorturl.at", "t.co", "tiny.cc", "tinyurl.com", "v.gd",
+	}
+)
+
+// Resolver return location which url redirects to or url itself when it does not redirect
+type Resolver interface {
+	Resolve(raw string) (string, error)
+}
+
+type httpResolver struct {
+	client *http.Client
+}
+
+// NewHttpResolver initial resolver which request url without following redirect
+func NewHttpResolver() *httpResolver {
+	return &httpResolver{
+		client: &http.Client{
+			Timeout: resolveTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (r *httpResolver) Resolve(raw string) (string, error) {
+	res, err := r.client.Get(raw)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	location := res.Header.Get(fiber.HeaderLocation)
+	if res.StatusCode < 300 || res.StatusCode >= 400 || location == "" {
+		return raw, nil
+	}
+	next, err := res.Request.URL.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return next.String(), nil
+}
+
+// destination validate url and check it does not point to this service or other shorteners,
+// with resolve policy the final destination is returned instead, used by Create and Update
+func (u *service) destination(c *fiber.Ctx, raw string) (string, error) {
+	if err := u.validateUrl(raw); err != nil {
+		return raw, err
+	}
+
+	current := raw
+	visited := map[string]bool{}
+	for hop := 0; ; hop++ {
+		parsed, err := neturl.Parse(current)
+		if err != nil {
+			return raw, err
+		}
+		host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+		own, shortener := u.isOwnHost(c, host), matchDomain(host, u.config.ShortenerDomains)
+		if !own && !shortener {
+			break
+		}
+		if u.config.ShortenerPolicy != PolicyResolve {
+			return raw, ErrURLShortener
+		}
+		if hop >= maxResolveHops || visited[current] {
+			return raw, ErrRedirectLoop
+		}
+		visited[current] = true
+
+		var next string
+		if own {
+			// short code of this service is resolved from repository without request
+			url, err := u.resolve(strings.Trim(parsed.Path, "/"), time.Now())
+			if err != nil || url.PasswordHash != "" {
+				// protected url is not resolved so its destination is not revealed
+				return raw, ErrURLShortener
+			}
+			next = url.FullUrl
+		} else {
+			// only shortener domains are requested so user input can not make the service call arbitrary hosts
+			if next, err = u.config.Resolver.Resolve(current); err != nil {
+				return raw, err
+			}
+			if next == current {
+				// shortener page which does not redirect
+				return raw, ErrURLShortener
+			}
+		}
+		current = next
+	}
+
+	if current != raw {
+		// final destination must pass the same validation
+		if err := u.validateUrl(current); err != nil {
+			return raw, err
+		}
+	}
+	return current, nil
+}
+
+// isOwnHost report whether host is host of request or one of OwnHosts
+func (u *service) isOwnHost(c *fiber.Ctx, host string) bool {
+	requestHost := c.Hostname()
+	if h, _, err := net.SplitHostPort(requestHost); err == nil {
+		requestHost = h
+	}
+	return host == strings.ToLower(requestHost) || matchDomain(host, u.config.OwnHosts)
+}
+
+// matchDomain report whether host is one of domains or their subdomain
+func matchDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
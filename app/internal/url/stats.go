@@ -0,0 +1,111 @@
+package url
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"log"
+	"rabbit-shorten-url/internal/url/models"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// defaultStatsDays number of days in time series of stats
+	defaultStatsDays = 30
+	// maxStatsDays limit of days query
+	maxStatsDays = 366
+
+	maxReferrerLength       = 2000
+	maxUserAgentLength      = 512
+	maxAcceptLanguageLength = 255
+)
+
+// ClickStats summary of clicks of a short_code
+type ClickStats struct {
+	TotalClicks    int64         `json:"total_clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	Daily          []DailyClicks `json:"daily"`
+}
+
+// DailyClicks number of clicks and unique visitors in a day (yyyy-mm-dd)
+type DailyClicks struct {
+	Date           string `json:"date"`
+	Clicks         int64  `json:"clicks"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+// StatsResponse return click stats of short_code
+type StatsResponse struct {
+	ShortCode string `json:"short_code"`
+	ClickStats
+}
+
+// Stats is used to show total clicks, unique visitors and daily time series of short_code
+func (u *service) Stats(c *fiber.Ctx) error {
+	code := c.Params("code")
+
+	days, err := strconv.Atoi(c.Query("days", strconv.Itoa(defaultStatsDays)))
+	if err != nil || days <= 0 || days > maxStatsDays {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{ErrInvalidDays.Error()})
+	}
+
+	if _, err := u.repo.GetByCode(code); errors.Is(err, ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	since := time.Now().AddDate(0, 0, -days+1)
+	since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, since.Location())
+	stats, err := u.repo.GetClickStats(code, since)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	return c.JSON(StatsResponse{code, stats})
+}
+
+// recordClick store click event of redirect or buffer it in HitCounter, failure is logged without breaking the redirect
+func (u *service) recordClick(c *fiber.Ctx, code string) {
+	// values of fiber context are reused after handler returns, they must be copied
+	click := models.Click{
+		ShortCode:      utils.CopyString(code),
+		ClickedAt:      time.Now(),
+		Referrer:       truncate(utils.CopyString(c.Get(fiber.HeaderReferer)), maxReferrerLength),
+		UserAgent:      truncate(utils.CopyString(c.Get(fiber.HeaderUserAgent)), maxUserAgentLength),
+		IpHash:         u.hashIp(c.IP()),
+		AcceptLanguage: truncate(utils.CopyString(c.Get(fiber.HeaderAcceptLanguage)), maxAcceptLanguageLength),
+	}
+	if u.config.HitCounter != nil {
+		u.config.HitCounter.AddClick(click)
+	} else if err := u.repo.InsertClick(&click); err != nil {
+		log.Printf("could not record click of %s: %v", code, err)
+	}
+}
+
+// hashIp return hmac-sha256 hex of client ip keyed by salt, raw ip is never stored for privacy
+// and it can not be found by hashing every ipv4 address without the salt
+func (u *service) hashIp(ip string) string {
+	mac := hmac.New(sha256.New, []byte(u.config.IpHashSalt))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// truncate cut s to fit in column length on rune boundary, invalid utf-8 is dropped so insert to utf8mb4 column does not fail
+func truncate(s string, length int) string {
+	s = strings.ToValidUTF8(s, "")
+	if len(s) <= length {
+		return s
+	}
+	for length > 0 && !utf8.RuneStart(s[length]) {
+		length--
+	}
+	return s[:length]
+}
@@ -0,0 +1,98 @@
+package url
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"sync"
+	"time"
+)
+
+// SweeperStatus last run and processed counts of Sweeper
+type SweeperStatus struct {
+	Running        bool       `json:"running"`
+	Interval       string     `json:"interval"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastProcessed  int64      `json:"last_processed"`
+	TotalProcessed int64      `json:"total_processed"`
+	Runs           int64      `json:"runs"`
+	LastError      string     `json:"last_error"`
+}
+
+// Sweeper periodically mark urls which are past their expiry date with status expired
+type Sweeper struct {
+	repo     Repository
+	interval time.Duration
+
+	mu     sync.RWMutex
+	status SweeperStatus
+
+	worker worker
+}
+
+// NewSweeper initial sweeper which sweep every interval after Start
+func NewSweeper(repo Repository, interval time.Duration) *Sweeper {
+	return &Sweeper{
+		repo:     repo,
+		interval: interval,
+		status: SweeperStatus{
+			Interval: interval.String(),
+		},
+		worker: newWorker(),
+	}
+}
+
+// Sweep mark expired urls at now and record result in status
+func (s *Sweeper) Sweep() (int64, error) {
+	now := time.Now()
+	processed, err := s.repo.MarkExpired(now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.LastRunAt = &now
+	s.status.LastProcessed = processed
+	s.status.TotalProcessed += processed
+	s.status.Runs++
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	return processed, err
+}
+
+// Status return copy of current status
+func (s *Sweeper) Status() SweeperStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Start sweep every interval in background until Stop
+func (s *Sweeper) Start() {
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+
+	s.worker.run(s.interval, func() {
+		if _, err := s.Sweep(); err != nil {
+			log.Printf("could not sweep expired urls: %v", err)
+		}
+	})
+}
+
+// Stop background sweep
+func (s *Sweeper) Stop() {
+	s.worker.stopWait()
+
+	s.mu.Lock()
+	s.status.Running = false
+	s.mu.Unlock()
+}
+
+// SweeperStatus is used to show last run time and processed counts of expiry sweeper
+func (u *service) SweeperStatus(c *fiber.Ctx) error {
+	if u.config.Sweeper == nil {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrSweeperDisabled.Error()})
+	}
+	return c.JSON(u.config.Sweeper.Status())
+}
@@ -0,0 +1,45 @@
+package url
+
+import (
+	"rabbit-shorten-url/internal/url/models"
+	"testing"
+	"time"
+)
+
+func TestSweeper(t *testing.T) {
+	repo := NewMemoryRepository()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	for _, url := range []models.Url{
+		{ShortCode: "expired", Status: models.StatusActive, ExpiryDate: &past},
+		{ShortCode: "active", Status: models.StatusActive, ExpiryDate: &future},
+		{ShortCode: "forever", Status: models.StatusActive},
+	} {
+		url := url
+		if err := repo.Insert(&url); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	s := NewSweeper(repo, time.Hour)
+	if processed, err := s.Sweep(); err != nil || processed != 1 {
+		t.Errorf("Sweep() = %v, %v, want 1", processed, err)
+	}
+	if processed, err := s.Sweep(); err != nil || processed != 0 {
+		t.Errorf("Sweep() again = %v, %v, want 0", processed, err)
+	}
+
+	url, _ := repo.GetByCode("expired")
+	if url.Status != models.StatusExpired {
+		t.Errorf("Status = %v, want %v", url.Status, models.StatusExpired)
+	}
+	url, _ = repo.GetByCode("active")
+	if url.Status != models.StatusActive {
+		t.Errorf("Status = %v, want %v", url.Status, models.StatusActive)
+	}
+
+	status := s.Status()
+	if status.Runs != 2 || status.TotalProcessed != 1 || status.LastProcessed != 0 || status.LastRunAt == nil {
+		t.Errorf("Status() = %+v, want 2 runs and 1 processed", status)
+	}
+}
@@ -0,0 +1,243 @@
+package url
+
+import (
+	"bufio"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"io/ioutil"
+	"log"
+	"net"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scanBatchSize number of urls checked per repository call of threat scan
+const scanBatchSize = 1000
+
+var (
+	// ErrURLThreat is the error in case of host of url is listed in a threat feed
+	ErrURLThreat = errors.New("url is listed as malicious")
+)
+
+// ThreatFeeds malicious hosts of feed files in a directory, supported formats are
+// plain domain lists, hosts files ("0.0.0.0 evil.example"), url lists and adblock rules ("||evil.example^")
+type ThreatFeeds struct {
+	dir string
+
+	mu       sync.RWMutex
+	hosts    map[string]string
+	loadedAt time.Time
+}
+
+// NewThreatFeeds initial threat feeds of directory, Reload must be called to load feeds
+func NewThreatFeeds(dir string) *ThreatFeeds {
+	return &ThreatFeeds{
+		dir:   dir,
+		hosts: map[string]string{},
+	}
+}
+
+// Reload read every feed file of directory, current feeds are kept when reading fails
+func (t *ThreatFeeds) Reload() error {
+	files, err := ioutil.ReadDir(t.dir)
+	if err != nil {
+		return err
+	}
+
+	hosts := map[string]string{}
+	for _, file := range files {
+		if !file.Mode().IsRegular() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		if err := readThreatFeed(filepath.Join(t.dir, file.Name()), hosts); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	t.hosts = hosts
+	t.loadedAt = time.Now()
+	t.mu.Unlock()
+	return nil
+}
+
+// readThreatFeed add hosts of feed file to hosts with file name as feed name
+func readThreatFeed(path string, hosts map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	name := filepath.Base(path)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if host := parseThreatLine(scanner.Text()); host != "" {
+			hosts[host] = name
+		}
+	}
+	return scanner.Err()
+}
+
+// parseThreatLine return host of feed line or empty string for comments and unsupported lines
+func parseThreatLine(line string) string {
+	if index := strings.Index(line, "#"); index >= 0 {
+		line = line[:index]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "!") {
+		return ""
+	}
+
+	entry := fields[0]
+	switch {
+	case len(fields) >= 2 && net.ParseIP(fields[0]) != nil:
+		// hosts file
+		entry = fields[1]
+	case strings.HasPrefix(entry, "||"):
+		// adblock rule
+		entry = strings.TrimSuffix(strings.TrimPrefix(entry, "||"), "^")
+	case strings.Contains(entry, "://"):
+		u, err := neturl.Parse(entry)
+		if err != nil {
+			return ""
+		}
+		entry = u.Hostname()
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(entry), ".")
+	if host == "localhost" || strings.ContainsAny(host, "/*") || net.ParseIP(host) != nil && net.ParseIP(host).IsUnspecified() {
+		return ""
+	}
+	return host
+}
+
+// Lookup return name of feed which lists host or one of its parent domains
+func (t *ThreatFeeds) Lookup(host string) (string, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for host != "" {
+		if feed, ok := t.hosts[host]; ok {
+			return feed, true
+		}
+		index := strings.Index(host, ".")
+		if index < 0 {
+			break
+		}
+		host = host[index+1:]
+	}
+	return "", false
+}
+
+// Check custom rule for threat feed validation
+func (t *ThreatFeeds) Check(value interface{}) error {
+	s, _ := value.(string)
+	host := urlHost(s)
+	if host == "" {
+		// invalid url is reported by is.URL
+		return nil
+	}
+	if _, ok := t.Lookup(host); ok {
+		return ErrURLThreat
+	}
+	return nil
+}
+
+// ThreatScanner periodically reload threat feeds and flag existing urls whose host is listed in a feed,
+// flagged urls are also disabled when disable is set
+type ThreatScanner struct {
+	repo     Repository
+	feeds    *ThreatFeeds
+	cache    *ResolveCache
+	disable  bool
+	interval time.Duration
+
+	worker worker
+}
+
+// NewThreatScanner initial threat scanner which scan every interval after Start, cache is invalidated for disabled urls
+func NewThreatScanner(repo Repository, feeds *ThreatFeeds, cache *ResolveCache, disable bool, interval time.Duration) *ThreatScanner {
+	return &ThreatScanner{
+		repo:     repo,
+		feeds:    feeds,
+		cache:    cache,
+		disable:  disable,
+		interval: interval,
+		worker:   newWorker(),
+	}
+}
+
+// Scan reload feeds then flag urls which are not deleted or flagged yet, return number of flagged urls
+func (s *ThreatScanner) Scan() (int64, error) {
+	if err := s.feeds.Reload(); err != nil {
+		return 0, err
+	}
+
+	var flagged int64
+	now := time.Now()
+	for after := ""; ; {
+		urls, err := s.repo.ScanUrls(after, scanBatchSize)
+		if err != nil {
+			return flagged, err
+		}
+		for _, url := range urls {
+			// url stored without scheme is matched by its host too
+			host := urlHost(url.FullUrl)
+			if host == "" {
+				continue
+			}
+			feed, ok := s.feeds.Lookup(host)
+			if !ok {
+				continue
+			}
+			if err := s.repo.FlagThreat(url.ShortCode, feed, s.disable, now); err != nil {
+				return flagged, err
+			}
+			if s.cache != nil {
+				s.cache.Invalidate(url.ShortCode)
+			}
+			flagged++
+		}
+		if len(urls) < scanBatchSize {
+			return flagged, nil
+		}
+		after = urls[len(urls)-1].ShortCode
+	}
+}
+
+// Start scan every interval in background until Stop
+func (s *ThreatScanner) Start() {
+	s.worker.run(s.interval, func() {
+		flagged, err := s.Scan()
+		if err != nil {
+			log.Printf("could not scan urls against threat feeds: %v", err)
+		}
+		if flagged > 0 {
+			log.Printf("flagged %d urls listed in threat feeds", flagged)
+		}
+	})
+}
+
+// Stop background scan
+func (s *ThreatScanner) Stop() {
+	s.worker.stopWait()
+}
+
+// ThreatReport is used to list urls flagged by threat scan, accepts the same query as List
+func (u *service) ThreatReport(c *fiber.Ctx) error {
+	query, err := parseSearchQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+	}
+	flagged := true
+	query.Flagged = &flagged
+
+	return u.list(c, query)
+}
@@ -0,0 +1,102 @@
+package url
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"rabbit-shorten-url/internal/url/models"
+	"testing"
+	"time"
+)
+
+func Test_parseThreatLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"should parse domain", "Evil.Example.", "evil.example"},
+		{"should parse hosts file", "0.0.0.0 phish.example # comment", "phish.example"},
+		{"should parse url", "http://malware.example:8080/payload.exe", "malware.example"},
+		{"should parse adblock rule", "||tracker.example^", "tracker.example"},
+		{"should skip comment", "# phish.example", ""},
+		{"should skip adblock comment", "! Title: feed", ""},
+		{"should skip localhost of hosts file", "127.0.0.1 localhost", ""},
+		{"should skip unspecified ip", "0.0.0.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseThreatLine(tt.line); got != tt.want {
+				t.Errorf("parseThreatLine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreatScanner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feeds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFeed := func(content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, "phishing.txt"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFeed("evil.example\n")
+	feeds := NewThreatFeeds(dir)
+	if err := feeds.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if err := feeds.Check("https://login.evil.example/"); err != ErrURLThreat {
+		t.Errorf("Check() error = %v, want %v", err, ErrURLThreat)
+	}
+	if err := feeds.Check("login.evil.example/path"); err != ErrURLThreat {
+		t.Errorf("Check() without scheme error = %v, want %v", err, ErrURLThreat)
+	}
+	if err := feeds.Check("https://notevil.example/"); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+
+	repo := NewMemoryRepository()
+	for _, url := range []models.Url{
+		{ShortCode: "clean", FullUrl: "https://docs.gofiber.io/"},
+		{ShortCode: "later", FullUrl: "https://www.bad.example/login"},
+		{ShortCode: "deleted", FullUrl: "https://bad.example/", IsDeleted: true},
+		{ShortCode: "noscheme", FullUrl: "bad.example/login"},
+	} {
+		url := url
+		if err := repo.Insert(&url); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// host appears in feed after url is created
+	writeFeed("evil.example\nbad.example\n")
+	scanner := NewThreatScanner(repo, feeds, nil, true, time.Minute)
+	flagged, err := scanner.Scan()
+	if err != nil || flagged != 2 {
+		t.Fatalf("Scan() = %v, %v, want 2", flagged, err)
+	}
+
+	url, _ := repo.GetByCode("later")
+	if url.ThreatFeed != "phishing.txt" || url.FlaggedAt == nil || !url.IsDisabled {
+		t.Errorf("flagged url = %+v", url)
+	}
+	if url, _ := repo.GetByCode("noscheme"); url.ThreatFeed != "phishing.txt" {
+		t.Errorf("url without scheme = %+v", url)
+	}
+	if url, _ := repo.GetByCode("clean"); url.ThreatFeed != "" || url.IsDisabled {
+		t.Errorf("clean url = %+v", url)
+	}
+	if url, _ := repo.GetByCode("deleted"); url.ThreatFeed != "" {
+		t.Errorf("deleted url = %+v", url)
+	}
+
+	// flagged url is not scanned again
+	if flagged, _ := scanner.Scan(); flagged != 0 {
+		t.Errorf("second Scan() = %v, want 0", flagged)
+	}
+}
@@ -0,0 +1,187 @@
+package url
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"html/template"
+	"rabbit-shorten-url/internal/url/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderLinkPassword header of password of protected url for api clients
+	HeaderLinkPassword = "X-Link-Password"
+
+	passwordMinLength = 4
+	// passwordMaxLength bcrypt ignores bytes after 72
+	passwordMaxLength = 72
+
+	defaultUnlockAttempts = 5
+	defaultUnlockLockout  = 15 * time.Minute
+)
+
+var (
+	ErrPasswordRequired = errors.New("password is required")
+	ErrPasswordInvalid  = errors.New("password is invalid")
+	ErrLocked           = errors.New("too many wrong passwords, try again later")
+)
+
+// unlockPage html form to enter password of protected url
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Protected link</title>
+<style>
+body{font-family:-apple-system,Helvetica,Arial,sans-serif;background:#f6f7f9;color:#333;text-align:center;padding:10vh 1em}
+input,button{font-size:1.1em;padding:.4em}
+.error{color:#e5534b}
+</style>
+</head>
+<body>
+<h1>Protected link</h1>
+<p>Enter the password of /{{.Code}} to continue.</p>
+<form method="post">
+<input type="password" name="password" aria-label="Password" autofocus required>
+<button type="submit">Unlock</button>
+</form>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+</body>
+</html>
+`))
+
+// UnlockPage data of unlock form template
+type UnlockPage struct {
+	Code  string
+	Error string
+}
+
+type unlockEntry struct {
+	attempts    int
+	firstAt     time.Time
+	lockedUntil time.Time
+}
+
+// UnlockGuard lock short_code after attempts wrong passwords within lockout, the lock lasts lockout
+type UnlockGuard struct {
+	attempts int
+	lockout  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]unlockEntry
+}
+
+// NewUnlockGuard initial in-memory brute-force guard of protected urls, locks are per instance
+func NewUnlockGuard(attempts int, lockout time.Duration) *UnlockGuard {
+	return &UnlockGuard{
+		attempts: attempts,
+		lockout:  lockout,
+		entries:  map[string]unlockEntry{},
+	}
+}
+
+// Locked return remaining lock of short_code at now
+func (g *UnlockGuard) Locked(code string, now time.Time) (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entry, ok := g.entries[code]
+	if !ok || !now.Before(entry.lockedUntil) {
+		return 0, false
+	}
+	return entry.lockedUntil.Sub(now), true
+}
+
+// TryAttempt reserve an attempt of password of short_code at now before it is checked, so concurrent wrong
+// passwords can not pass the limit together, it is refused with remaining lock when short_code is locked
+// and the attempt which reaches the limit locks short_code until Reset
+func (g *UnlockGuard) TryAttempt(code string, now time.Time) (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entry := g.entries[code]
+	if now.Before(entry.lockedUntil) {
+		return entry.lockedUntil.Sub(now), false
+	}
+	if now.Sub(entry.firstAt) > g.lockout {
+		entry = unlockEntry{firstAt: now}
+	}
+	entry.attempts++
+	if entry.attempts >= g.attempts {
+		entry = unlockEntry{firstAt: now, lockedUntil: now.Add(g.lockout)}
+	}
+	g.entries[code] = entry
+	return 0, true
+}
+
+// Reset forget attempts and lock of short_code after it is unlocked
+func (g *UnlockGuard) Reset(code string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.entries, code)
+}
+
+// hashPassword return bcrypt hash of password of url, empty password is not hashed
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// unlock check password of protected url from HeaderLinkPassword or posted unlock form
+func (u *service) unlock(c *fiber.Ctx, url models.Url) error {
+	now := time.Now()
+	if wait, locked := u.unlockGuard.Locked(url.ShortCode, now); locked {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait.Seconds())+1))
+		return ErrLocked
+	}
+
+	password := c.Get(HeaderLinkPassword)
+	if password == "" && c.Method() == fiber.MethodPost {
+		password = c.FormValue("password")
+	}
+	if password == "" {
+		return ErrPasswordRequired
+	}
+
+	// attempt is counted before comparing and forgotten only when password is right
+	if wait, ok := u.unlockGuard.TryAttempt(url.ShortCode, now); !ok {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(wait.Seconds())+1))
+		return ErrLocked
+	}
+	if bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)) != nil {
+		return ErrPasswordInvalid
+	}
+	u.unlockGuard.Reset(url.ShortCode)
+	return nil
+}
+
+// sendUnlock respond error of unlock as html form when it is accepted, otherwise json
+func (u *service) sendUnlock(c *fiber.Ctx, code string, err error) error {
+	status := fiber.StatusUnauthorized
+	if errors.Is(err, ErrLocked) {
+		status = fiber.StatusTooManyRequests
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	if c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML) == fiber.MIMETextHTML {
+		page := UnlockPage{Code: code}
+		if !errors.Is(err, ErrPasswordRequired) {
+			page.Error = err.Error()
+		}
+		var buf bytes.Buffer
+		if err := unlockPage.Execute(&buf, page); err == nil {
+			c.Type("html")
+			return c.Status(status).Send(buf.Bytes())
+		}
+	}
+
+	return c.Status(status).JSON(ErrResponse{err.Error()})
+}
@@ -0,0 +1,70 @@
+package url
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUnlockGuard(t *testing.T) {
+	g := NewUnlockGuard(2, time.Minute)
+	now := time.Date(2021, 2, 24, 0, 0, 0, 0, time.UTC)
+
+	g.TryAttempt("secret", now)
+	if _, locked := g.Locked("secret", now); locked {
+		t.Errorf("Locked() after one attempt = true, want false")
+	}
+
+	// failures outside window are forgotten
+	g.TryAttempt("secret", now.Add(2*time.Minute))
+	if _, locked := g.Locked("secret", now.Add(2*time.Minute)); locked {
+		t.Errorf("Locked() after expired attempt = true, want false")
+	}
+
+	g.TryAttempt("secret", now.Add(2*time.Minute+time.Second))
+	wait, locked := g.Locked("secret", now.Add(2*time.Minute+time.Second))
+	if !locked || wait != time.Minute {
+		t.Errorf("Locked() = %v, %v, want %v, true", wait, locked, time.Minute)
+	}
+	if _, locked := g.Locked("other", now); locked {
+		t.Errorf("Locked() of other code = true, want false")
+	}
+	if _, locked := g.Locked("secret", now.Add(3*time.Minute+time.Second)); locked {
+		t.Errorf("Locked() after lockout = true, want false")
+	}
+
+	g.TryAttempt("other", now)
+	g.Reset("other")
+	g.TryAttempt("other", now)
+	if _, locked := g.Locked("other", now); locked {
+		t.Errorf("Locked() after Reset() = true, want false")
+	}
+}
+
+func TestUnlockGuard_ConcurrentAttempts(t *testing.T) {
+	g := NewUnlockGuard(5, time.Minute)
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := g.TryAttempt("secret", now); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 5 {
+		t.Errorf("TryAttempt() allowed %v concurrent attempts, want 5", allowed)
+	}
+	if _, locked := g.Locked("secret", now); !locked {
+		t.Errorf("Locked() after concurrent attempts = false, want true")
+	}
+}
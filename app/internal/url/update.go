@@ -0,0 +1,111 @@
+package url
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"rabbit-shorten-url/internal/url/models"
+	"time"
+)
+
+// UpdateRequest handle incoming patch request to change attributes of shorten url, omitted field is unchanged
+// and expiry 0 removes expiry date
+type UpdateRequest struct {
+	Url          *string `json:"url"`
+	Expiry       *Expiry `json:"expiry"`
+	RedirectType *int    `json:"redirect_type"`
+}
+
+// Update is used to change destination, expiry or redirect type of short_code and keep previous values in history
+func (u *service) Update(c *fiber.Ctx) error {
+	code := c.Params("code")
+	req := new(UpdateRequest)
+
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+	}
+
+	url, err := u.repo.GetByCode(code)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	history := models.UrlHistory{
+		ShortCode:    url.ShortCode,
+		FullUrl:      url.FullUrl,
+		ExpiryDate:   url.ExpiryDate,
+		RedirectType: url.RedirectType,
+		ChangedAt:    time.Now(),
+	}
+	// columns changed by request, others are left to concurrent writers like threat scan and expiry sweeper
+	var columns []string
+
+	if req.Url != nil {
+		destination, err := u.destination(c, *req.Url)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+		}
+		url.FullUrl = destination
+		url.UrlHash = hashUrl(destination)
+		// new destination passed screening so flag of threat scan is cleared
+		url.ThreatFeed = ""
+		url.FlaggedAt = nil
+		url.IsDisabled = false
+		columns = append(columns, "full_url", "url_hash", "threat_feed", "flagged_at", "is_disabled")
+	}
+	if req.Expiry != nil {
+		expiryDate, err := u.expiryDate(*req.Expiry, time.Now())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+		}
+		if url.StartsAt != nil && expiryDate != nil && !url.StartsAt.Before(*expiryDate) {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{ErrStartsAfterExpiry.Error()})
+		}
+		url.ExpiryDate = expiryDate
+		// new expiry reactivates url which was marked by expiry sweeper
+		url.Status = models.StatusActive
+		if url.ExpiryDate != nil && !url.ExpiryDate.After(time.Now()) {
+			url.Status = models.StatusExpired
+		}
+		columns = append(columns, "expiry_date", "status")
+	}
+	if req.RedirectType != nil {
+		if err := validateRedirectType(*req.RedirectType); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+		}
+		if url.MaxHits > 0 && isPermanentRedirect(*req.RedirectType) {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{ErrPermanentLimited.Error()})
+		}
+		url.RedirectType = *req.RedirectType
+		columns = append(columns, "redirect_type")
+	}
+
+	if len(columns) == 0 {
+		return c.JSON(url)
+	}
+	if err := u.repo.Update(&url, columns, &history); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+	u.invalidate(code)
+
+	return c.JSON(url)
+}
+
+// History is used to list previous destinations of short_code, newest first
+func (u *service) History(c *fiber.Ctx) error {
+	code := c.Params("code")
+
+	if _, err := u.repo.GetByCode(code); errors.Is(err, ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	histories, err := u.repo.GetHistory(code)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	return c.JSON(histories)
+}
@@ -2,10 +2,11 @@ package url
 
 import (
 	"errors"
+	"fmt"
 	"github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
+	"rabbit-shorten-url/internal/auth"
 	"rabbit-shorten-url/internal/url/models"
 	"time"
 )
@@ -16,23 +17,52 @@ type Service interface {
 	Create(c *fiber.Ctx) error
 	List(c *fiber.Ctx) error
 	SoftDelete(c *fiber.Ctx) error
+	Stats(c *fiber.Ctx) error
+	Update(c *fiber.Ctx) error
+	History(c *fiber.Ctx) error
+	Restore(c *fiber.Ctx) error
+	Purge(c *fiber.Ctx) error
+	SweeperStatus(c *fiber.Ctx) error
+	MyUrls(c *fiber.Ctx) error
+	BlockRules(c *fiber.Ctx) error
+	AddBlockRule(c *fiber.Ctx) error
+	DeleteBlockRule(c *fiber.Ctx) error
+	ReloadBlockList(c *fiber.Ctx) error
+	ThreatReport(c *fiber.Ctx) error
+	DeleteOwn(c *fiber.Ctx) error
 }
 
 type service struct {
-	db *gorm.DB
+	repo        Repository
+	config      Config
+	unlockGuard *UnlockGuard
 }
 
-// New initial url service with dbClient
-func New(dbClient *gorm.DB) *service {
+// New initial url service with repository and config
+func New(repo Repository, config Config) *service {
+	config = configDefault(config)
 	return &service{
-		db: dbClient,
+		repo:        repo,
+		config:      config,
+		unlockGuard: NewUnlockGuard(config.UnlockAttempts, config.UnlockLockout),
 	}
 }
 
-// CreateRequest handle incoming post request to create new shorten url with expiry (hour)
+// CreateRequest handle incoming post request to create new shorten url with optional expiry and alias,
+// reuse overrides Config.ReuseExisting and redirect_type overrides Config.DefaultRedirectType for this request,
+// password protects redirect of url, max_hits limits number of redirects and one_time is the same as max_hits 1,
+// starts_at schedules activation of url and fallback_url is redirected to before it
 type CreateRequest struct {
-	Url    string        `json:"url"`
-	Expiry time.Duration `json:"expiry"`
+	Url          string     `json:"url"`
+	Expiry       Expiry     `json:"expiry"`
+	Alias        string     `json:"alias"`
+	Reuse        *bool      `json:"reuse"`
+	RedirectType int        `json:"redirect_type"`
+	Password     string     `json:"password"`
+	MaxHits      int        `json:"max_hits"`
+	OneTime      bool       `json:"one_time"`
+	StartsAt     *time.Time `json:"starts_at"`
+	FallbackUrl  string     `json:"fallback_url"`
 }
 
 // CreateResponse return shorten url of incoming request
@@ -51,106 +81,374 @@ type SuccessResponse struct {
 }
 
 var (
-	ErrExpired  = errors.New("expired")
-	ErrNotFound = errors.New("not found")
+	ErrExpired          = errors.New("expired")
+	ErrDeleted          = errors.New("deleted")
+	ErrDisabled         = errors.New("disabled")
+	ErrExhausted        = errors.New("click limit is reached")
+	ErrNotYetActive     = errors.New("not yet available")
+	ErrNotFound         = errors.New("not found")
+	ErrDuplicated       = errors.New("duplicated")
+	ErrAliasTaken       = errors.New("alias is already taken")
+	ErrCodeExhausted    = errors.New("could not generate unique short code")
+	ErrInvalidDays      = errors.New("days must be between 1 and 366")
+	ErrInvalidRetention = errors.New("retention must be greater than zero")
+	ErrSweeperDisabled  = errors.New("expiry sweeper is disabled")
+	ErrPermanentLimited = errors.New("redirect_type: must be temporary for click limited url")
 )
 
 // Create is used to generate shorten service from request
 func (u *service) Create(c *fiber.Ctx) error {
-	// shortCodeLength define characters of shortCode
-	shortCodeLength := 8
 	req := new(CreateRequest)
 
 	if err := c.BodyParser(req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
 	}
 
-	if err := validation.Validate(req.Url,
-		validation.Required,           // not empty
-		validation.By(checkBlockList), // is a block list
-		is.URL,                        // is a valid URL
+	destination, err := u.destination(c, req.Url)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+	}
+	req.Url = destination
+
+	if err := validation.Validate(req.Alias,
+		validation.Length(aliasMinLength, aliasMaxLength), // length of short_code
+		validation.Match(regExAlias),                      // allowed characters
+		validation.By(checkReservedAlias),                 // is a reserved word
 	); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
 	}
 
-	var expiryDate *time.Time
-	if req.Expiry > 0 {
-		exp := time.Now().Add(req.Expiry * time.Hour)
-		expiryDate = &exp
+	if err := validateRedirectType(req.RedirectType); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
 	}
 
-	var shortCode string
-	isShortCodeDuplicated := true
-	for isShortCodeDuplicated {
-		// check if short_code is duplicated or not
-		shortCode = generateRandomString(shortCodeLength)
-		result := u.db.First(&models.Url{}, "short_code", shortCode)
-		if result.RowsAffected <= 0 {
-			isShortCodeDuplicated = false
+	if err := validation.Validate(req.Password, validation.Length(passwordMinLength, passwordMaxLength)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{"password: " + err.Error()})
+	}
+	if err := validation.Validate(req.MaxHits, validation.Min(0)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{"max_hits: " + err.Error()})
+	}
+	if req.OneTime {
+		if req.MaxHits > 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{"max_hits: must be 1 for one time url"})
+		}
+		req.MaxHits = 1
+	}
+	if req.MaxHits > 0 && isPermanentRedirect(req.RedirectType) {
+		// browsers reuse cached permanent redirect without reaching the server, so its clicks are not counted
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{ErrPermanentLimited.Error()})
+	}
+
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+	if req.RedirectType == 0 {
+		req.RedirectType = u.config.DefaultRedirectType
+	}
+
+	urlHash := hashUrl(req.Url)
+
+	expiryDate, err := u.expiryDate(req.Expiry, time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+	}
+	if err := u.validateSchedule(c, req, expiryDate); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
+	}
+
+	reuse := u.config.ReuseExisting
+	if req.Reuse != nil {
+		reuse = *req.Reuse
+	}
+	if reuse && req.Alias == "" && req.Password == "" && req.MaxHits == 0 && req.StartsAt == nil {
+		// return active short_code of the same url, expiry, redirect type and owner instead of creating new one
+		query := ReuseQuery{
+			UrlHash:       urlHash,
+			ExpiryDate:    expiryDate,
+			RedirectTypes: []int{req.RedirectType},
+			Owner:         auth.Owner(c),
+		}
+		if apiKey, ok := auth.ApiKeyFromContext(c); ok {
+			query.ApiKeyID = &apiKey.ID
+		}
+		if req.RedirectType == u.config.DefaultRedirectType {
+			query.RedirectTypes = append(query.RedirectTypes, 0)
+		}
+		existing, err := u.repo.GetReusable(query)
+		if err == nil {
+			return c.Status(fiber.StatusOK).JSON(CreateResponse{c.Hostname() + "/" + existing.ShortCode})
+		} else if !errors.Is(err, ErrNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
 		}
 	}
 
 	url := models.Url{
-		ShortCode:  shortCode,
-		FullUrl:    req.Url,
-		ExpiryDate: expiryDate,
+		ShortCode:    req.Alias,
+		FullUrl:      req.Url,
+		UrlHash:      urlHash,
+		ExpiryDate:   expiryDate,
+		RedirectType: req.RedirectType,
+		Status:       models.StatusActive,
+		PasswordHash: passwordHash,
+		MaxHits:      req.MaxHits,
+		StartsAt:     req.StartsAt,
+		FallbackUrl:  req.FallbackUrl,
+	}
+	// record api key or account which created the url
+	if apiKey, ok := auth.ApiKeyFromContext(c); ok {
+		url.ApiKeyID = &apiKey.ID
 	}
+	url.Owner = auth.Owner(c)
 
-	u.db.Create(&url)
+	if req.Alias != "" {
+		// alias must not be used by another url
+		_, err := u.repo.GetByCode(req.Alias)
+		if err == nil {
+			return c.Status(fiber.StatusConflict).JSON(ErrResponse{ErrAliasTaken.Error()})
+		} else if !errors.Is(err, ErrNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+		}
+
+		if err := u.repo.Insert(&url); errors.Is(err, ErrDuplicated) {
+			// alias was taken by concurrent request
+			return c.Status(fiber.StatusConflict).JSON(ErrResponse{ErrAliasTaken.Error()})
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+		}
+		return c.Status(fiber.StatusCreated).JSON(CreateResponse{c.Hostname() + "/" + url.ShortCode})
+	}
+
+	for attempt := 0; url.ShortCode == ""; attempt++ {
+		if attempt >= maxGenerateAttempts {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{ErrCodeExhausted.Error()})
+		}
+
+		// check if short_code is duplicated or not
+		code, err := u.config.CodeGenerator.Generate(req.Url, attempt)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+		}
+		_, err = u.repo.GetByCode(code)
+		if err == nil {
+			continue
+		} else if !errors.Is(err, ErrNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+		}
+
+		url.ShortCode = code
+		if err := u.repo.Insert(&url); errors.Is(err, ErrDuplicated) {
+			// generated code was taken by concurrent request, try another one
+			url.ShortCode = ""
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+		}
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(CreateResponse{c.Hostname() + "/" + url.ShortCode})
 }
 
-// Redirect is used to find valid service from shorten service then redirect to (302)
+// validateUrl validate destination of shorten url, used by destination
+func (u *service) validateUrl(value string) error {
+	rules := []validation.Rule{
+		validation.Required,             // not empty
+		is.URL,                          // is a valid URL
+		validation.By(checkAbsoluteUrl), // has http or https scheme and host
+	}
+	if u.config.BlockList != nil {
+		rules = append(rules, validation.By(u.config.BlockList.Check)) // is a block list
+	}
+	if u.config.ThreatFeeds != nil {
+		rules = append(rules, validation.By(u.config.ThreatFeeds.Check)) // is listed in threat feed
+	}
+	return validation.Validate(value, rules...)
+}
+
+// isPermanentRedirect report whether redirect status is cached by browsers as permanent
+func isPermanentRedirect(status int) bool {
+	return status == fiber.StatusMovedPermanently || status == fiber.StatusPermanentRedirect
+}
+
+// validateRedirectType validate redirect status, zero means default
+func validateRedirectType(value int) error {
+	return validation.Validate(value,
+		validation.In(RedirectTypes...), // is a redirect status
+	)
+}
+
+// Redirect is used to find valid service from shorten service then redirect with redirect_type of url,
+// unknown short_code is 404 and deleted or expired one is 410
 func (u *service) Redirect(c *fiber.Ctx) error {
 	code := c.Params("code")
 
-	var url models.Url
-	u.db.First(&url, "short_code", code)
+	url, err := u.resolve(code, time.Now())
+	if errors.Is(err, ErrNotYetActive) && url.FallbackUrl != "" {
+		// fallback is temporary so it is never cached as permanent redirect
+		return c.Redirect(url.FallbackUrl, fiber.StatusFound)
+	} else if err != nil {
+		return u.sendError(c, code, err)
+	}
+	if url.PasswordHash != "" {
+		if err := u.unlock(c, url); err != nil {
+			return u.sendUnlock(c, code, err)
+		}
+		// redirect of protected url must not be cached by browsers or proxies
+		c.Set(fiber.HeaderCacheControl, "no-store")
+	}
+
+	if url.MaxHits > 0 {
+		// redirect of limited url must not be cached so every click reaches the server, even with permanent default
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		// hit of limited url is written synchronously, repository decides which click takes the last hit
+		if err := u.repo.ConsumeHit(url.ShortCode); errors.Is(err, ErrExhausted) {
+			return u.sendError(c, code, err)
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+		}
+	} else if u.config.HitCounter != nil {
+		u.config.HitCounter.Add(url.ShortCode, 1)
+	} else if err := u.repo.IncrementHits(url.ShortCode, 1); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+	u.recordClick(c, url.ShortCode)
+
+	redirectType := url.RedirectType
+	if redirectType == 0 {
+		redirectType = u.config.DefaultRedirectType
+	}
+	if c.Method() == fiber.MethodPost {
+		// unlock form is answered with GET redirect, 307 and 308 would resend its password to destination
+		redirectType = fiber.StatusSeeOther
+	}
+	return c.Redirect(url.FullUrl, redirectType)
+}
+
+// resolve find url by short_code and check that it can be redirected at now
+func (u *service) resolve(code string, now time.Time) (models.Url, error) {
+	url, err := u.getByCode(code)
+	if err != nil {
+		return url, err
+	}
+	if url.IsDeleted {
+		return url, ErrDeleted
+	}
+	if url.IsDisabled {
+		return url, ErrDisabled
+	}
+	if url.MaxHits > 0 && url.Hits >= url.MaxHits {
+		return url, ErrExhausted
+	}
+	if url.StartsAt != nil && now.Before(*url.StartsAt) {
+		return url, fmt.Errorf("%w, starts at %s", ErrNotYetActive, url.StartsAt.Format(time.RFC3339))
+	}
+	if url.Status == models.StatusExpired || (url.ExpiryDate != nil && !url.ExpiryDate.After(now)) {
+		return url, ErrExpired
+	}
+	return url, nil
+}
 
-	if url.ExpiryDate != nil && (url.ExpiryDate.Sub(time.Now()) <= 0 || url.IsDeleted) {
-		return c.Status(fiber.StatusGone).JSON(ErrResponse{ErrExpired.Error()})
+// getByCode find url by short_code from cache then repository
+func (u *service) getByCode(code string) (models.Url, error) {
+	if u.config.Cache == nil {
+		return u.repo.GetByCode(code)
 	}
 
-	url.Hits += 1
-	u.db.Model(&url).Update("hits", url.Hits)
+	if url, ok := u.config.Cache.Get(code); ok {
+		return url, nil
+	}
+	url, err := u.repo.GetByCode(code)
+	if err != nil {
+		return url, err
+	}
+	u.config.Cache.Set(url)
+	return url, nil
+}
 
-	return c.Redirect(url.FullUrl)
+// invalidate remove cached url of short_code after it is changed
+func (u *service) invalidate(code string) {
+	if u.config.Cache != nil {
+		u.config.Cache.Invalidate(code)
+	}
 }
 
-// List is used to list details by short_code or keyword on full_url
+// List is used to list details by short_code, or a page of urls filtered by keyword on full_url,
+// deleted, expired, expiry_from, expiry_to and min_hits, sorted by created_at, hits or expiry_date
 func (u *service) List(c *fiber.Ctx) error {
 	code := c.Params("code")
-	fullUrl := c.Query("full_url")
-	var url []models.Url
 
 	if code != "" {
-		result := u.db.First(&url, "short_code", code)
-		if result.RowsAffected <= 0 {
+		url, err := u.repo.GetByCode(code)
+		if errors.Is(err, ErrNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
 		}
-		return c.JSON(url[0])
+		url.State = urlState(url, time.Now())
+		return c.JSON(url)
 	}
 
-	// init chain orm
-	tx := u.db
-	if fullUrl != "" {
-		tx = u.db.Where("full_url LIKE ?", "%"+fullUrl+"%")
+	query, err := parseSearchQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrResponse{err.Error()})
 	}
 
-	tx.Find(&url)
+	return u.list(c, query)
+}
+
+// list return a page of urls matching query, used by List and MyUrls
+func (u *service) list(c *fiber.Ctx, query SearchQuery) error {
+	total, err := u.repo.Count(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+
+	// fetch one more url to know whether there is next page
+	limit := query.Limit
+	query.Limit++
+	urls, err := u.repo.Search(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
 
-	return c.JSON(url)
+	for i := range urls {
+		urls[i].State = urlState(urls[i], query.Now)
+	}
+	res := ListResponse{Data: urls, Total: total}
+	if len(urls) > limit {
+		res.Data = urls[:limit]
+		res.NextCursor = encodeCursor(sortValue(urls[limit-1], query.Sort))
+		res.Next = nextLink(c, res.NextCursor)
+	}
+
+	return c.JSON(res)
 }
 
 // SoftDelete is used to mark flag is_deleted = true by short_code
 func (u *service) SoftDelete(c *fiber.Ctx) error {
 	code := c.Params("code")
 
-	result := u.db.Model(&models.Url{}).Where("short_code = ?", code).Update("is_deleted", true)
-	if result.RowsAffected <= 0 {
+	err := u.repo.SoftDelete(code)
+	if errors.Is(err, ErrNotFound) {
 		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
 	}
+	u.invalidate(code)
 
 	return c.Status(fiber.StatusOK).JSON(SuccessResponse{code + " has been deleted"})
 }
+
+// Restore is used to mark flag is_deleted = false by short_code
+func (u *service) Restore(c *fiber.Ctx) error {
+	code := c.Params("code")
+
+	err := u.repo.Restore(code)
+	if errors.Is(err, ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(ErrResponse{ErrNotFound.Error()})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(ErrResponse{err.Error()})
+	}
+	u.invalidate(code)
+
+	return c.Status(fiber.StatusOK).JSON(SuccessResponse{code + " has been restored"})
+}
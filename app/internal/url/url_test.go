@@ -1,7 +1,10 @@
 package url
 
 import (
+	"database/sql/driver"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/gofiber/fiber/v2"
@@ -11,13 +14,41 @@ import (
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"rabbit-shorten-url/internal/auth"
+	"rabbit-shorten-url/internal/url/models"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
+// insertUrlQuery is the statement of creating models.Url with insertUrlColumns columns
+const (
+	insertUrlQuery   = "INSERT INTO `urls` (`short_code`,`full_url`,`url_hash`,`starts_at`,`expiry_date`,`hits`,`max_hits`,`is_deleted`,`deleted_at`,`redirect_type`,`status`,`api_key_id`,`owner`,`threat_feed`,`flagged_at`,`is_disabled`,`password_hash`,`fallback_url`,`created_at`) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+	insertUrlColumns = 19
+)
+
+// anyArgs return n sqlmock.AnyArg
+func anyArgs(n int) []driver.Value {
+	args := make([]driver.Value, n)
+	for i := range args {
+		args[i] = sqlmock.AnyArg()
+	}
+	return args
+}
+
+// newBlockList return loaded block list of one rule
+func (s *TSuite) newBlockList(kind, pattern string) *BlockList {
+	repo := NewMemoryRepository()
+	s.Require().NoError(repo.InsertBlockRule(&models.BlockRule{Kind: kind, Pattern: pattern}))
+	blockList := NewBlockList(repo, "", time.Minute)
+	s.Require().NoError(blockList.Reload())
+	return blockList
+}
+
 type TSuite struct {
 	suite.Suite
 	DB   *gorm.DB
@@ -42,7 +73,7 @@ func (s *TSuite) SetupSuite() {
 }
 
 func (s *TSuite) TestCreateUrl_ShouldReturnBodyParserError() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	app.Post("/", u.Create)
 
@@ -59,7 +90,7 @@ func (s *TSuite) TestCreateUrl_ShouldReturnBodyParserError() {
 }
 
 func (s *TSuite) TestCreateUrl_UrlIsNotValid() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	app.Post("/", u.Create)
 
@@ -79,7 +110,7 @@ func (s *TSuite) TestCreateUrl_UrlIsNotValid() {
 }
 
 func (s *TSuite) TestCreateUrl_UrlIsBlockList() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{BlockList: s.newBlockList(models.BlockSuffix, "facebook.com")})
 	app := fiber.New()
 	app.Post("/", u.Create)
 
@@ -98,8 +129,25 @@ func (s *TSuite) TestCreateUrl_UrlIsBlockList() {
 	s.Assert().Contains(string(body), ErrURLBlockList.Error())
 }
 
+func (s *TSuite) TestCreateUrl_UrlWithoutScheme() {
+	u := New(NewMemoryRepository(), Config{BlockList: s.newBlockList(models.BlockSuffix, "facebook.com")})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	for _, url := range []string{"facebook.com/x", "www.facebook.com", "docs.gofiber.io", "ftp://docs.gofiber.io/"} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "`+url+`"}`))
+		req.Header.Add("Content-Type", "application/json")
+
+		res, _ := app.Test(req, -1)
+		body, _ := ioutil.ReadAll(res.Body)
+
+		s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode, url)
+		s.Assert().Contains(string(body), ErrURLNotAbsolute.Error(), url)
+	}
+}
+
 func (s *TSuite) TestCreateUrl_Success() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	app.Post("/", u.Create)
 
@@ -108,8 +156,8 @@ func (s *TSuite) TestCreateUrl_Success() {
 		WithArgs(sqlmock.AnyArg()).
 		WillReturnRows(rs)
 
-	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `urls` (`short_code`,`full_url`,`expiry_date`,`hits`,`is_deleted`) VALUES (?,?,?,?,?)")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+	s.mock.ExpectExec(regexp.QuoteMeta(insertUrlQuery)).
+		WithArgs(anyArgs(insertUrlColumns)...).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	var reqBody = `{
@@ -126,7 +174,7 @@ func (s *TSuite) TestCreateUrl_Success() {
 }
 
 func (s *TSuite) TestCreateUrl_SuccessButShortCodeIsDuplicated() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	app.Post("/", u.Create)
 
@@ -139,8 +187,8 @@ func (s *TSuite) TestCreateUrl_SuccessButShortCodeIsDuplicated() {
 		WithArgs(sqlmock.AnyArg()).
 		WillReturnRows(rs)
 
-	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `urls` (`short_code`,`full_url`,`expiry_date`,`hits`,`is_deleted`) VALUES (?,?,?,?,?)")).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+	s.mock.ExpectExec(regexp.QuoteMeta(insertUrlQuery)).
+		WithArgs(anyArgs(insertUrlColumns)...).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	var reqBody = `{
@@ -156,8 +204,313 @@ func (s *TSuite) TestCreateUrl_SuccessButShortCodeIsDuplicated() {
 	s.Assert().Equal(fiber.StatusCreated, res.StatusCode)
 }
 
+func (s *TSuite) TestCreateUrl_HashStrategyWithoutReuse() {
+	generator, err := NewCodeGenerator(StrategyHash, 8, "")
+	s.Require().NoError(err)
+	u := New(NewMemoryRepository(), Config{CodeGenerator: generator})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	// every deterministic code of the url is used after a few requests
+	for i := 0; i < maxGenerateAttempts+2; i++ {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/"}`))
+		req.Header.Add("Content-Type", "application/json")
+		res, _ := app.Test(req, -1)
+		s.Require().Equal(fiber.StatusCreated, res.StatusCode, i)
+	}
+}
+
+// racingRepository report duplicated short_code on first insert as if concurrent request took it
+type racingRepository struct {
+	*memoryRepository
+	raced bool
+}
+
+func (r *racingRepository) Insert(url *models.Url) error {
+	if !r.raced {
+		r.raced = true
+		return ErrDuplicated
+	}
+	return r.memoryRepository.Insert(url)
+}
+
+func (s *TSuite) TestCreateUrl_GeneratedCodeIsTakenConcurrently() {
+	u := New(&racingRepository{memoryRepository: NewMemoryRepository()}, Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/"}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusCreated, res.StatusCode)
+	s.Assert().NotContains(string(body), ErrAliasTaken.Error())
+
+	// alias is not retried
+	u = New(&racingRepository{memoryRepository: NewMemoryRepository()}, Config{})
+	app = fiber.New()
+	app.Post("/", u.Create)
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/", "alias": "spring-sale"}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ = app.Test(req, -1)
+	s.Assert().Equal(fiber.StatusConflict, res.StatusCode)
+}
+
+func (s *TSuite) TestCreateUrl_AliasIsNotValid() {
+	u := New(NewGormRepository(s.DB), Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	for _, alias := range []string{"ab", "spring/sale", "spring sale", "ADMIN"} {
+		reqBody := `{"url": "https://docs.gofiber.io/", "alias": "` + alias + `"}`
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(reqBody))
+		req.Header.Add("Content-Type", "application/json")
+
+		res, _ := app.Test(req, -1)
+
+		s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode, alias)
+	}
+}
+
+func (s *TSuite) TestCreateUrl_AliasIsTaken() {
+	u := New(NewGormRepository(s.DB), Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	alias := "spring-sale"
+	rs := sqlmock.NewRows([]string{"short_code"})
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` WHERE `short_code` = ? ORDER BY `urls`.`short_code` LIMIT 1")).
+		WithArgs(alias).
+		WillReturnRows(rs.AddRow(alias))
+
+	var reqBody = `{
+		"url": "https://docs.gofiber.io/",
+		"alias": "` + alias + `"
+	}`
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(reqBody))
+	req.Header.Add("Content-Type", "application/json")
+
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusConflict, res.StatusCode)
+	s.Assert().Contains(string(body), ErrAliasTaken.Error())
+}
+
+func (s *TSuite) TestCreateUrl_AliasSuccess() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	alias := "spring-sale"
+	var reqBody = `{
+		"url": "https://docs.gofiber.io/",
+		"alias": "` + alias + `"
+	}`
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(reqBody))
+	req.Header.Add("Content-Type", "application/json")
+
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusCreated, res.StatusCode)
+	s.Assert().Contains(string(body), "/"+alias)
+
+	url, err := repo.GetByCode(alias)
+	s.Require().NoError(err)
+	s.Assert().Equal("https://docs.gofiber.io/", url.FullUrl)
+}
+
+func (s *TSuite) TestCreateUrl_ReuseExisting() {
+	u := New(NewMemoryRepository(), Config{ReuseExisting: true})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	create := func(reqBody string) (int, string) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(reqBody))
+		req.Header.Add("Content-Type", "application/json")
+		res, _ := app.Test(req, -1)
+		body, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	status, first := create(`{"url": "https://docs.gofiber.io/"}`)
+	s.Assert().Equal(fiber.StatusCreated, status)
+
+	status, again := create(`{"url": "https://DOCS.gofiber.io:443/#intro"}`)
+	s.Assert().Equal(fiber.StatusOK, status)
+	s.Assert().Equal(first, again)
+
+	status, other := create(`{"url": "https://docs.gofiber.io/", "reuse": false}`)
+	s.Assert().Equal(fiber.StatusCreated, status)
+	s.Assert().NotEqual(first, other)
+
+	// url with another redirect type or expiry is not the same
+	for _, reqBody := range []string{
+		`{"url": "https://docs.gofiber.io/", "redirect_type": 301}`,
+		`{"url": "https://docs.gofiber.io/", "expiry": "24h"}`,
+	} {
+		status, other := create(reqBody)
+		s.Assert().Equal(fiber.StatusCreated, status, reqBody)
+		s.Assert().NotEqual(first, other, reqBody)
+	}
+	// explicit default redirect type is the same, first or other url with it is reused
+	status, again = create(`{"url": "https://docs.gofiber.io/", "redirect_type": 302}`)
+	s.Assert().Equal(fiber.StatusOK, status)
+	s.Assert().Contains([]string{first, other}, again)
+
+	at := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+	status, first = create(`{"url": "https://docs.gofiber.io/", "expiry": "` + at + `"}`)
+	s.Assert().Equal(fiber.StatusCreated, status)
+	status, again = create(`{"url": "https://docs.gofiber.io/", "expiry": "` + at + `"}`)
+	s.Assert().Equal(fiber.StatusOK, status)
+	s.Assert().Equal(first, again)
+}
+
+func (s *TSuite) TestCreateUrl_ReuseOwnUrl() {
+	authService := auth.New(auth.NewMemoryRepository())
+	keyA, _, err := authService.IssueApiKey("a")
+	s.Require().NoError(err)
+	keyB, _, err := authService.IssueApiKey("b")
+	s.Require().NoError(err)
+
+	u := New(NewMemoryRepository(), Config{ReuseExisting: true})
+	app := fiber.New()
+	app.Post("/", auth.ApiKey(authService, false), u.Create)
+
+	create := func(key string) (int, string) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/"}`))
+		req.Header.Add("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Add(auth.HeaderApiKey, key)
+		}
+		res, _ := app.Test(req, -1)
+		body, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	_, first := create(keyA)
+	status, again := create(keyA)
+	s.Assert().Equal(fiber.StatusOK, status)
+	s.Assert().Equal(first, again)
+
+	for _, key := range []string{keyB, ""} {
+		status, other := create(key)
+		s.Assert().Equal(fiber.StatusCreated, status)
+		s.Assert().NotEqual(first, other)
+	}
+}
+
+func (s *TSuite) TestCreateUrl_ReuseSkipsInactive() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{ReuseExisting: true})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	hash := hashUrl("https://docs.gofiber.io/")
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: "disabled", FullUrl: "https://docs.gofiber.io/", UrlHash: hash, IsDisabled: true}))
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: "swept", FullUrl: "https://docs.gofiber.io/", UrlHash: hash, Status: models.StatusExpired}))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/"}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	s.Assert().Equal(fiber.StatusCreated, res.StatusCode)
+}
+
+func (s *TSuite) TestGetReusable_Query() {
+	repo := NewGormRepository(s.DB)
+	rs := sqlmock.NewRows([]string{"short_code"})
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` WHERE (url_hash = ? AND is_deleted = ? AND is_disabled = ? AND status <> ?) AND (password_hash = ? AND max_hits = ? AND starts_at IS NULL) AND redirect_type IN (?,?) AND owner = ? AND api_key_id IS NULL AND expiry_date IS NULL ORDER BY `urls`.`short_code` LIMIT 1")).
+		WithArgs("hash", false, false, models.StatusExpired, "", 0, 302, 0, "").
+		WillReturnRows(rs)
+
+	_, err := repo.GetReusable(ReuseQuery{UrlHash: "hash", RedirectTypes: []int{302, 0}})
+	s.Assert().Equal(ErrNotFound, err)
+}
+
+func (s *TSuite) TestCreateUrl_ApiKey() {
+	repo := NewMemoryRepository()
+	authService := auth.New(auth.NewMemoryRepository())
+	key, apiKey, err := authService.IssueApiKey("ci")
+	s.Require().NoError(err)
+
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Post("/", auth.ApiKey(authService, true), u.Create)
+
+	create := func(key, alias string) int {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/", "alias": "`+alias+`"}`))
+		req.Header.Add("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Add(auth.HeaderApiKey, key)
+		}
+		res, _ := app.Test(req, -1)
+		return res.StatusCode
+	}
+
+	s.Assert().Equal(fiber.StatusUnauthorized, create("", "no-key"))
+	s.Assert().Equal(fiber.StatusUnauthorized, create("rk_unknown", "bad-key"))
+	s.Assert().Equal(fiber.StatusCreated, create(key, "with-key"))
+
+	url, err := repo.GetByCode("with-key")
+	s.Require().NoError(err)
+	s.Require().NotNil(url.ApiKeyID)
+	s.Assert().Equal(apiKey.ID, *url.ApiKeyID)
+}
+
+func (s *TSuite) TestCreateUrl_RedirectTypeIsNotValid() {
+	u := New(NewMemoryRepository(), Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	const reqBody = `{
+		"url": "https://docs.gofiber.io/",
+		"redirect_type": 303
+	}`
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(reqBody))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+
+	s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode)
+}
+
+func (s *TSuite) TestRedirectUrl_RedirectType() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{DefaultRedirectType: fiber.StatusTemporaryRedirect})
+	app := fiber.New()
+	app.Post("/", u.Create)
+	app.Get("/:code", u.Redirect)
+
+	tests := []struct {
+		alias        string
+		redirectType string
+		want         int
+	}{
+		{"permanent", `, "redirect_type": 301`, fiber.StatusMovedPermanently},
+		{"campaign", `, "redirect_type": 302`, fiber.StatusFound},
+		{"default", ``, fiber.StatusTemporaryRedirect},
+	}
+	for _, tt := range tests {
+		reqBody := `{"url": "https://docs.gofiber.io/", "alias": "` + tt.alias + `"` + tt.redirectType + `}`
+		req := httptest.NewRequest("POST", "/", strings.NewReader(reqBody))
+		req.Header.Add("Content-Type", "application/json")
+		res, _ := app.Test(req, -1)
+		s.Require().Equal(fiber.StatusCreated, res.StatusCode)
+
+		res, _ = app.Test(httptest.NewRequest("GET", "/"+tt.alias, nil), -1)
+		s.Assert().Equal(tt.want, res.StatusCode, tt.alias)
+		s.Assert().Equal("https://docs.gofiber.io/", res.Header.Get("Location"))
+	}
+}
+
 func (s *TSuite) TestRedirectUrl_ShortCodeIsExpired() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	app.Get("/:code", u.Redirect)
 	shortCode := "test1234"
@@ -176,7 +529,7 @@ func (s *TSuite) TestRedirectUrl_ShortCodeIsExpired() {
 }
 
 func (s *TSuite) TestRedirectUrl_ShortCodeIsDeleted() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	app.Get("/:code", u.Redirect)
 	shortCode := "test1234"
@@ -194,8 +547,73 @@ func (s *TSuite) TestRedirectUrl_ShortCodeIsDeleted() {
 	s.Assert().Equal(fiber.StatusGone, res.StatusCode)
 }
 
+func (s *TSuite) TestRedirectUrl_ShortCodeIsNotFound() {
+	u := New(NewGormRepository(s.DB), Config{})
+	app := fiber.New()
+	app.Get("/:code", u.Redirect)
+	shortCode := "test1234"
+
+	rs := sqlmock.NewRows([]string{"short_code", "full_url", "expiry_date", "hits", "is_deleted"})
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` WHERE `short_code` = ? ORDER BY `urls`.`short_code` LIMIT 1")).
+		WithArgs(shortCode).
+		WillReturnRows(rs)
+
+	req := httptest.NewRequest("GET", "/"+shortCode, nil)
+	req.Header.Add("Content-Type", "application/json")
+
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusNotFound, res.StatusCode)
+	s.Assert().Contains(string(body), ErrNotFound.Error())
+}
+
+func (s *TSuite) TestRedirectUrl_ShortCodeIsDeletedWithoutExpiry() {
+	u := New(NewGormRepository(s.DB), Config{})
+	app := fiber.New()
+	app.Get("/:code", u.Redirect)
+	shortCode := "test1234"
+
+	rs := sqlmock.NewRows([]string{"short_code", "full_url", "expiry_date", "hits", "is_deleted"})
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` WHERE `short_code` = ? ORDER BY `urls`.`short_code` LIMIT 1")).
+		WithArgs(shortCode).
+		WillReturnRows(rs.AddRow(shortCode, "https://www.google.com", nil, 0, 1))
+
+	req := httptest.NewRequest("GET", "/"+shortCode, nil)
+	req.Header.Add("Content-Type", "application/json")
+
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusGone, res.StatusCode)
+	s.Assert().Contains(string(body), ErrDeleted.Error())
+}
+
+func (s *TSuite) TestRedirectUrl_ErrorPage() {
+	u := New(NewMemoryRepository(), Config{ErrorPage: DefaultErrorPage()})
+	app := fiber.New()
+	app.Get("/:code", u.Redirect)
+
+	req := httptest.NewRequest("GET", "/test1234", nil)
+	req.Header.Add("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusNotFound, res.StatusCode)
+	s.Assert().Contains(res.Header.Get("Content-Type"), "text/html")
+	s.Assert().Contains(string(body), "Not Found")
+
+	req = httptest.NewRequest("GET", "/test1234", nil)
+	req.Header.Add("Accept", "application/json")
+	res, _ = app.Test(req, -1)
+	body, _ = ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusNotFound, res.StatusCode)
+	s.Assert().Contains(string(body), `{"error":"not found"}`)
+}
+
 func (s *TSuite) TestRedirectUrl_Success() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	app.Get("/:code", u.Redirect)
 	shortCode := "test1234"
@@ -204,9 +622,12 @@ func (s *TSuite) TestRedirectUrl_Success() {
 	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` WHERE `short_code` = ? ORDER BY `urls`.`short_code` LIMIT 1")).
 		WithArgs(shortCode).
 		WillReturnRows(rs.AddRow(shortCode, "https://www.google.com", nil, hits, 0))
-	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `urls` SET `hits`=? WHERE `short_code` = ?")).
-		WithArgs(hits+1, shortCode).
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `urls` SET `hits`=hits + ? WHERE short_code = ?")).
+		WithArgs(1, shortCode).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `clicks` (`short_code`,`clicked_at`,`referrer`,`user_agent`,`ip_hash`,`accept_language`) VALUES (?,?,?,?,?,?)")).
+		WithArgs(shortCode, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	req := httptest.NewRequest("GET", "/"+shortCode, nil)
 	req.Header.Add("Content-Type", "application/json")
@@ -216,8 +637,33 @@ func (s *TSuite) TestRedirectUrl_Success() {
 	s.Assert().Equal(fiber.StatusFound, res.StatusCode)
 }
 
+func (s *TSuite) TestRedirectUrl_CacheIsInvalidatedOnDelete() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{Cache: NewResolveCache(time.Hour, 100)})
+	app := fiber.New()
+	app.Get("/:code", u.Redirect)
+	app.Delete("/admin/urls/:code", u.SoftDelete)
+
+	shortCode := "test1234"
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: shortCode, FullUrl: "https://www.google.com"}))
+
+	for i := 0; i < 2; i++ {
+		res, _ := app.Test(httptest.NewRequest("GET", "/"+shortCode, nil), -1)
+		s.Assert().Equal(fiber.StatusFound, res.StatusCode)
+	}
+
+	url, _ := repo.GetByCode(shortCode)
+	s.Assert().Equal(2, url.Hits)
+
+	res, _ := app.Test(httptest.NewRequest("DELETE", "/admin/urls/"+shortCode, nil), -1)
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+
+	res, _ = app.Test(httptest.NewRequest("GET", "/"+shortCode, nil), -1)
+	s.Assert().Equal(fiber.StatusGone, res.StatusCode)
+}
+
 func (s *TSuite) TestListUrl_IsNotAuthenticated() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	admin := app.Group("/admin", basicauth.New(basicauth.Config{
 		Users: map[string]string{
@@ -235,7 +681,7 @@ func (s *TSuite) TestListUrl_IsNotAuthenticated() {
 }
 
 func (s *TSuite) TestListUrl_ListAll_Success() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	admin := app.Group("/admin", basicauth.New(basicauth.Config{
 		Users: map[string]string{
@@ -245,7 +691,9 @@ func (s *TSuite) TestListUrl_ListAll_Success() {
 	admin.Get("/urls/:code?", u.List)
 
 	rs := sqlmock.NewRows([]string{"short_code", "full_url", "expiry_date", "hits", "is_deleted"})
-	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls`")).
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT count(1) FROM `urls`")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` ORDER BY created_at DESC, short_code DESC LIMIT 51")).
 		WillReturnRows(rs.AddRow("test", "https://www.google.com", time.Now().Add(time.Hour), 0, 0))
 
 	req := httptest.NewRequest("GET", "/admin/urls", nil)
@@ -259,7 +707,7 @@ func (s *TSuite) TestListUrl_ListAll_Success() {
 }
 
 func (s *TSuite) TestListUrl_ListByShortCode_NotFound() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	admin := app.Group("/admin", basicauth.New(basicauth.Config{
 		Users: map[string]string{
@@ -284,7 +732,7 @@ func (s *TSuite) TestListUrl_ListByShortCode_NotFound() {
 }
 
 func (s *TSuite) TestListUrl_ListByShortCode_Success() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	admin := app.Group("/admin", basicauth.New(basicauth.Config{
 		Users: map[string]string{
@@ -309,7 +757,7 @@ func (s *TSuite) TestListUrl_ListByShortCode_Success() {
 }
 
 func (s *TSuite) TestListUrl_ListByFullUrlKeyword_Success() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	admin := app.Group("/admin", basicauth.New(basicauth.Config{
 		Users: map[string]string{
@@ -320,6 +768,9 @@ func (s *TSuite) TestListUrl_ListByFullUrlKeyword_Success() {
 
 	fullUrl := "google"
 	rs := sqlmock.NewRows([]string{"short_code", "full_url", "expiry_date", "hits", "is_deleted"})
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT count(1) FROM `urls` WHERE full_url LIKE ?")).
+		WithArgs("%" + fullUrl + "%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` WHERE full_url LIKE ?")).
 		WithArgs("%" + fullUrl + "%").
 		WillReturnRows(rs.AddRow("test1234", "https://www.google.com", time.Now().Add(time.Hour), 0, 0))
@@ -334,8 +785,166 @@ func (s *TSuite) TestListUrl_ListByFullUrlKeyword_Success() {
 	s.Assert().Contains(string(body), fullUrl)
 }
 
+func (s *TSuite) TestListUrl_QueryIsNotValid() {
+	u := New(NewMemoryRepository(), Config{})
+	app := fiber.New()
+	app.Get("/admin/urls/:code?", u.List)
+
+	for _, query := range []string{"sort=full_url", "order=up", "deleted=maybe", "expiry_from=tomorrow", "min_hits=-1", "limit=1000", "cursor=abc"} {
+		res, _ := app.Test(httptest.NewRequest("GET", "/admin/urls?"+query, nil), -1)
+		body, _ := ioutil.ReadAll(res.Body)
+
+		s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode, query)
+		s.Assert().Contains(string(body), strings.SplitN(query, "=", 2)[0], query)
+	}
+}
+
+func (s *TSuite) TestListUrl_PaginationSortingFiltering() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Get("/admin/urls/:code?", u.List)
+
+	past := time.Now().Add(-time.Hour)
+	for i, hits := range []int{5, 3, 8, 1, 3} {
+		url := models.Url{ShortCode: fmt.Sprintf("code%d", i), FullUrl: "https://www.google.com", Hits: hits}
+		if i == 4 {
+			url.ExpiryDate = &past
+		}
+		s.Require().NoError(repo.Insert(&url))
+	}
+
+	list := func(path string) ListResponse {
+		res, _ := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		s.Require().Equal(fiber.StatusOK, res.StatusCode, path)
+		var body ListResponse
+		s.Require().NoError(json.NewDecoder(res.Body).Decode(&body))
+		return body
+	}
+
+	var codes []string
+	page := list("/admin/urls?sort=hits&order=desc&limit=2")
+	s.Assert().Equal(int64(5), page.Total)
+	for page.Next != "" {
+		for _, url := range page.Data {
+			codes = append(codes, url.ShortCode)
+		}
+		s.Assert().Contains(page.Next, "limit=2")
+		page = list(strings.TrimPrefix(page.Next, "http://example.com"))
+	}
+	for _, url := range page.Data {
+		codes = append(codes, url.ShortCode)
+	}
+	s.Assert().Equal([]string{"code2", "code0", "code4", "code1", "code3"}, codes)
+
+	page = list("/admin/urls?expired=false&min_hits=3&sort=hits&order=asc")
+	s.Assert().Equal(int64(3), page.Total)
+	s.Assert().Empty(page.Next)
+	s.Require().Len(page.Data, 3)
+	s.Assert().Equal("code1", page.Data[0].ShortCode)
+
+	page = list("/admin/urls?expired=true")
+	s.Assert().Equal(int64(1), page.Total)
+	s.Require().Len(page.Data, 1)
+	s.Assert().Equal("code4", page.Data[0].ShortCode)
+}
+
+func (s *TSuite) TestListUrl_CursorQuery() {
+	u := New(NewGormRepository(s.DB), Config{})
+	app := fiber.New()
+	app.Get("/admin/urls/:code?", u.List)
+
+	cursor := encodeCursor(Cursor{Hits: 3, ShortCode: "test1234"})
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT count(1) FROM `urls` WHERE hits >= ?")).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` WHERE hits >= ? AND (hits < ? OR (hits = ? AND short_code < ?)) ORDER BY hits DESC, short_code DESC LIMIT 3")).
+		WithArgs(2, 3, 3, "test1234").
+		WillReturnRows(sqlmock.NewRows([]string{"short_code", "hits"}).AddRow("test0001", 2))
+
+	res, _ := app.Test(httptest.NewRequest("GET", "/admin/urls?sort=hits&min_hits=2&limit=2&cursor="+cursor, nil), -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+	s.Assert().Contains(string(body), `"total":4`)
+	s.Assert().NotContains(string(body), `"next"`)
+}
+
+func (s *TSuite) TestListUrl_ExpiryDatePagination() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Get("/admin/urls/:code?", u.List)
+
+	tomorrow, nextWeek := time.Now().Add(24*time.Hour), time.Now().Add(7*24*time.Hour)
+	for code, expiryDate := range map[string]*time.Time{"code0": nil, "code1": &nextWeek, "code2": nil, "code3": &tomorrow} {
+		s.Require().NoError(repo.Insert(&models.Url{ShortCode: code, FullUrl: "https://www.google.com", ExpiryDate: expiryDate}))
+	}
+
+	pages := func(path string) []string {
+		var codes []string
+		for path != "" {
+			res, _ := app.Test(httptest.NewRequest("GET", path, nil), -1)
+			s.Require().Equal(fiber.StatusOK, res.StatusCode, path)
+			var page ListResponse
+			s.Require().NoError(json.NewDecoder(res.Body).Decode(&page))
+			for _, url := range page.Data {
+				codes = append(codes, url.ShortCode)
+			}
+			path = strings.TrimPrefix(page.Next, "http://example.com")
+		}
+		return codes
+	}
+
+	// urls without expiry date are sorted after every expiry date
+	s.Assert().Equal([]string{"code3", "code1", "code0", "code2"}, pages("/admin/urls?sort=expiry_date&order=asc&limit=1"))
+	s.Assert().Equal([]string{"code2", "code0", "code1", "code3"}, pages("/admin/urls?sort=expiry_date&order=desc&limit=1"))
+}
+
+func (s *TSuite) TestListUrl_ExpiryDateCursorQuery() {
+	u := New(NewGormRepository(s.DB), Config{})
+	app := fiber.New()
+	app.Get("/admin/urls/:code?", u.List)
+
+	expiryDate := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		order  string
+		cursor Cursor
+		where  string
+		args   []driver.Value
+	}{
+		{"should continue ascending after expiry date", "asc", Cursor{Time: expiryDate, ShortCode: "test1234"},
+			"(expiry_date IS NULL OR expiry_date > ? OR (expiry_date = ? AND short_code > ?)) ORDER BY expiry_date IS NULL ASC, expiry_date ASC, short_code ASC",
+			[]driver.Value{expiryDate, expiryDate, "test1234"}},
+		{"should continue ascending after no expiry", "asc", Cursor{NoExpiry: true, ShortCode: "test1234"},
+			"(expiry_date IS NULL AND short_code > ?) ORDER BY expiry_date IS NULL ASC, expiry_date ASC, short_code ASC",
+			[]driver.Value{"test1234"}},
+		{"should continue descending after expiry date", "desc", Cursor{Time: expiryDate, ShortCode: "test1234"},
+			"(expiry_date < ? OR (expiry_date = ? AND short_code < ?)) ORDER BY expiry_date IS NULL DESC, expiry_date DESC, short_code DESC",
+			[]driver.Value{expiryDate, expiryDate, "test1234"}},
+		{"should continue descending after no expiry", "desc", Cursor{NoExpiry: true, ShortCode: "test1234"},
+			"(expiry_date IS NOT NULL OR short_code < ?) ORDER BY expiry_date IS NULL DESC, expiry_date DESC, short_code DESC",
+			[]driver.Value{"test1234"}},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT count(1) FROM `urls` WHERE hits >= ?")).
+				WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+			s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` WHERE hits >= ? AND " + tt.where + " LIMIT 3")).
+				WithArgs(append([]driver.Value{1}, tt.args...)...).
+				WillReturnRows(sqlmock.NewRows([]string{"short_code"}))
+
+			res, _ := app.Test(httptest.NewRequest("GET", "/admin/urls?min_hits=1&sort=expiry_date&order="+tt.order+"&limit=2&cursor="+encodeCursor(tt.cursor), nil), -1)
+			s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+			s.Assert().NoError(s.mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func (s *TSuite) TestSoftDeleteUrl_ShortCodeIsNotFound() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	admin := app.Group("/admin", basicauth.New(basicauth.Config{
 		Users: map[string]string{
@@ -345,8 +954,8 @@ func (s *TSuite) TestSoftDeleteUrl_ShortCodeIsNotFound() {
 	admin.Delete("/urls/:code?", u.SoftDelete)
 
 	shortCode := "test1234"
-	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `urls` SET `is_deleted`=? WHERE short_code = ?")).
-		WithArgs(true, shortCode).
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `urls` SET `deleted_at`=?,`is_deleted`=? WHERE short_code = ?")).
+		WithArgs(sqlmock.AnyArg(), true, shortCode).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	req := httptest.NewRequest("DELETE", "/admin/urls/"+shortCode, nil)
@@ -360,7 +969,7 @@ func (s *TSuite) TestSoftDeleteUrl_ShortCodeIsNotFound() {
 }
 
 func (s *TSuite) TestSoftDeleteUrl_Success() {
-	u := New(s.DB)
+	u := New(NewGormRepository(s.DB), Config{})
 	app := fiber.New()
 	admin := app.Group("/admin", basicauth.New(basicauth.Config{
 		Users: map[string]string{
@@ -370,8 +979,8 @@ func (s *TSuite) TestSoftDeleteUrl_Success() {
 	admin.Delete("/urls/:code?", u.SoftDelete)
 
 	shortCode := "test1234"
-	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `urls` SET `is_deleted`=? WHERE short_code = ?")).
-		WithArgs(true, shortCode).
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `urls` SET `deleted_at`=?,`is_deleted`=? WHERE short_code = ?")).
+		WithArgs(sqlmock.AnyArg(), true, shortCode).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	req := httptest.NewRequest("DELETE", "/admin/urls/"+shortCode, nil)
@@ -384,6 +993,830 @@ func (s *TSuite) TestSoftDeleteUrl_Success() {
 	s.Assert().Contains(string(body), shortCode)
 }
 
+func (s *TSuite) TestStatsUrl_ShortCodeIsNotFound() {
+	u := New(NewMemoryRepository(), Config{})
+	app := fiber.New()
+	app.Get("/admin/urls/:code/stats", u.Stats)
+
+	req := httptest.NewRequest("GET", "/admin/urls/test1234/stats", nil)
+	res, _ := app.Test(req, -1)
+
+	s.Assert().Equal(fiber.StatusNotFound, res.StatusCode)
+}
+
+func (s *TSuite) TestStatsUrl_DaysIsNotValid() {
+	u := New(NewMemoryRepository(), Config{})
+	app := fiber.New()
+	app.Get("/admin/urls/:code/stats", u.Stats)
+
+	req := httptest.NewRequest("GET", "/admin/urls/test1234/stats?days=0", nil)
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode)
+	s.Assert().Contains(string(body), ErrInvalidDays.Error())
+}
+
+func (s *TSuite) TestStatsUrl_Success() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Get("/:code", u.Redirect)
+	app.Get("/admin/urls/:code/stats", u.Stats)
+
+	shortCode := "test1234"
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: shortCode, FullUrl: "https://www.google.com"}))
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.1", "10.0.0.2"} {
+		req := httptest.NewRequest("GET", "/"+shortCode, nil)
+		req.Header.Add("Referer", "https://www.bing.com/")
+		req.Header.Add("X-Forwarded-For", ip)
+		req.RemoteAddr = ip + ":1234"
+		res, _ := app.Test(req, -1)
+		s.Require().Equal(fiber.StatusFound, res.StatusCode)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/urls/"+shortCode+"/stats", nil)
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+	s.Assert().Contains(string(body), `"total_clicks":3`)
+	s.Assert().Contains(string(body), `"date":"`+time.Now().Format(dateLayout)+`"`)
+}
+
+func (s *TSuite) TestUpdateUrl_ShortCodeIsNotFound() {
+	u := New(NewMemoryRepository(), Config{})
+	app := fiber.New()
+	app.Patch("/admin/urls/:code", u.Update)
+
+	req := httptest.NewRequest("PATCH", "/admin/urls/test1234", strings.NewReader(`{"url": "https://www.google.com"}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+
+	s.Assert().Equal(fiber.StatusNotFound, res.StatusCode)
+}
+
+func (s *TSuite) TestUpdateUrl_UrlIsBlockList() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{BlockList: s.newBlockList(models.BlockSuffix, "facebook.com")})
+	app := fiber.New()
+	app.Patch("/admin/urls/:code", u.Update)
+
+	shortCode := "test1234"
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: shortCode, FullUrl: "https://www.google.com"}))
+
+	req := httptest.NewRequest("PATCH", "/admin/urls/"+shortCode, strings.NewReader(`{"url": "https://www.facebook.com/"}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode)
+	s.Assert().Contains(string(body), ErrURLBlockList.Error())
+}
+
+func (s *TSuite) TestUpdateUrl_Success() {
+	u := New(NewGormRepository(s.DB), Config{})
+	app := fiber.New()
+	app.Patch("/admin/urls/:code", u.Update)
+
+	shortCode := "test1234"
+	rs := sqlmock.NewRows([]string{"short_code", "full_url", "expiry_date", "hits", "is_deleted", "redirect_type"})
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `urls` WHERE `short_code` = ? ORDER BY `urls`.`short_code` LIMIT 1")).
+		WithArgs(shortCode).
+		WillReturnRows(rs.AddRow(shortCode, "https://www.google.com", nil, 0, 0, 302))
+	s.mock.ExpectBegin()
+	// status and expiry_date are not part of request so they are not written
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `urls` SET `flagged_at`=?,`full_url`=?,`is_disabled`=?,`redirect_type`=?,`threat_feed`=?,`url_hash`=? WHERE short_code = ?")).
+		WithArgs(nil, "https://docs.gofiber.io/", false, 301, "", hashUrl("https://docs.gofiber.io/"), shortCode).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `url_histories` (`short_code`,`full_url`,`expiry_date`,`redirect_type`,`changed_at`) VALUES (?,?,?,?,?)")).
+		WithArgs(shortCode, "https://www.google.com", nil, 302, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mock.ExpectCommit()
+
+	req := httptest.NewRequest("PATCH", "/admin/urls/"+shortCode, strings.NewReader(`{"url": "https://docs.gofiber.io/", "redirect_type": 301}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+	s.Assert().Contains(string(body), "https://docs.gofiber.io/")
+}
+
+// flaggingRepository flag url by threat scan right after it is read as if scan ran concurrently with update
+type flaggingRepository struct {
+	*memoryRepository
+}
+
+func (r *flaggingRepository) GetByCode(code string) (models.Url, error) {
+	url, err := r.memoryRepository.GetByCode(code)
+	if err == nil {
+		err = r.memoryRepository.FlagThreat(code, "phishing", true, time.Now())
+	}
+	return url, err
+}
+
+func (s *TSuite) TestUpdateUrl_KeepsConcurrentChanges() {
+	repo := NewMemoryRepository()
+	u := New(&flaggingRepository{repo}, Config{})
+	app := fiber.New()
+	app.Patch("/admin/urls/:code", u.Update)
+
+	shortCode := "test1234"
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: shortCode, FullUrl: "https://www.google.com", RedirectType: 302}))
+
+	req := httptest.NewRequest("PATCH", "/admin/urls/"+shortCode, strings.NewReader(`{"redirect_type": 307}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	s.Require().Equal(fiber.StatusOK, res.StatusCode)
+
+	url, _ := repo.GetByCode(shortCode)
+	s.Assert().Equal(307, url.RedirectType)
+	s.Assert().Equal("phishing", url.ThreatFeed)
+	s.Assert().True(url.IsDisabled)
+}
+
+func (s *TSuite) TestUpdateUrl_ExpiryBeforeStartsAt() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Patch("/admin/urls/:code", u.Update)
+
+	shortCode := "test1234"
+	startsAt := time.Now().Add(48 * time.Hour)
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: shortCode, FullUrl: "https://www.google.com", StartsAt: &startsAt}))
+
+	req := httptest.NewRequest("PATCH", "/admin/urls/"+shortCode, strings.NewReader(`{"expiry": "24h"}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode)
+	s.Assert().Contains(string(body), ErrStartsAfterExpiry.Error())
+
+	req = httptest.NewRequest("PATCH", "/admin/urls/"+shortCode, strings.NewReader(`{"expiry": "72h"}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ = app.Test(req, -1)
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+}
+
+func (s *TSuite) TestHistoryUrl_Success() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Patch("/admin/urls/:code", u.Update)
+	app.Get("/admin/urls/:code/history", u.History)
+
+	shortCode := "test1234"
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: shortCode, FullUrl: "https://www.google.com", Hits: 5}))
+
+	for _, reqBody := range []string{`{"url": "https://docs.gofiber.io/"}`, `{"expiry": 24}`} {
+		req := httptest.NewRequest("PATCH", "/admin/urls/"+shortCode, strings.NewReader(reqBody))
+		req.Header.Add("Content-Type", "application/json")
+		res, _ := app.Test(req, -1)
+		s.Require().Equal(fiber.StatusOK, res.StatusCode)
+	}
+
+	url, _ := repo.GetByCode(shortCode)
+	s.Assert().Equal("https://docs.gofiber.io/", url.FullUrl)
+	s.Assert().NotNil(url.ExpiryDate)
+	s.Assert().Equal(5, url.Hits)
+
+	res, _ := app.Test(httptest.NewRequest("GET", "/admin/urls/"+shortCode+"/history", nil), -1)
+	var histories []models.UrlHistory
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&histories))
+
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+	s.Require().Len(histories, 2)
+	s.Assert().Equal("https://docs.gofiber.io/", histories[0].FullUrl)
+	s.Assert().Equal("https://www.google.com", histories[1].FullUrl)
+}
+
+func (s *TSuite) TestRestoreUrl_ShortCodeIsNotDeleted() {
+	u := New(NewGormRepository(s.DB), Config{})
+	app := fiber.New()
+	app.Post("/admin/urls/:code/restore", u.Restore)
+
+	shortCode := "test1234"
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `urls` SET `deleted_at`=?,`is_deleted`=? WHERE short_code = ? AND is_deleted = ?")).
+		WithArgs(nil, false, shortCode, true).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	res, _ := app.Test(httptest.NewRequest("POST", "/admin/urls/"+shortCode+"/restore", nil), -1)
+
+	s.Assert().Equal(fiber.StatusNotFound, res.StatusCode)
+}
+
+func (s *TSuite) TestRestoreUrl_Success() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{Cache: NewResolveCache(time.Hour, 100)})
+	app := fiber.New()
+	app.Get("/:code", u.Redirect)
+	app.Delete("/admin/urls/:code", u.SoftDelete)
+	app.Post("/admin/urls/:code/restore", u.Restore)
+
+	shortCode := "test1234"
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: shortCode, FullUrl: "https://www.google.com"}))
+
+	res, _ := app.Test(httptest.NewRequest("DELETE", "/admin/urls/"+shortCode, nil), -1)
+	s.Require().Equal(fiber.StatusOK, res.StatusCode)
+	res, _ = app.Test(httptest.NewRequest("GET", "/"+shortCode, nil), -1)
+	s.Require().Equal(fiber.StatusGone, res.StatusCode)
+
+	res, _ = app.Test(httptest.NewRequest("POST", "/admin/urls/"+shortCode+"/restore", nil), -1)
+	body, _ := ioutil.ReadAll(res.Body)
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+	s.Assert().Contains(string(body), shortCode)
+
+	res, _ = app.Test(httptest.NewRequest("GET", "/"+shortCode, nil), -1)
+	s.Assert().Equal(fiber.StatusFound, res.StatusCode)
+
+	url, _ := repo.GetByCode(shortCode)
+	s.Assert().Nil(url.DeletedAt)
+}
+
+func (s *TSuite) TestPurgeUrl_RetentionIsNotValid() {
+	u := New(NewMemoryRepository(), Config{})
+	app := fiber.New()
+	app.Post("/admin/purge", u.Purge)
+
+	for _, query := range []string{"", "?retention=abc", "?retention=-1h"} {
+		res, _ := app.Test(httptest.NewRequest("POST", "/admin/purge"+query, nil), -1)
+		s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode, query)
+	}
+}
+
+func (s *TSuite) TestPurgeUrl_Success() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{PurgeRetention: 24 * time.Hour})
+	app := fiber.New()
+	app.Post("/admin/purge", u.Purge)
+
+	longAgo := time.Now().Add(-48 * time.Hour)
+	recently := time.Now().Add(-time.Hour)
+	for _, url := range []models.Url{
+		{ShortCode: "deleted", IsDeleted: true, DeletedAt: &longAgo},
+		{ShortCode: "expired", ExpiryDate: &longAgo},
+		{ShortCode: "recent", IsDeleted: true, DeletedAt: &recently},
+		{ShortCode: "active"},
+	} {
+		url := url
+		s.Require().NoError(repo.Insert(&url))
+		s.Require().NoError(repo.InsertClick(&models.Click{ShortCode: url.ShortCode, ClickedAt: longAgo}))
+	}
+
+	res, _ := app.Test(httptest.NewRequest("POST", "/admin/purge", nil), -1)
+	body, _ := ioutil.ReadAll(res.Body)
+
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+	s.Assert().Contains(string(body), `"purged":2`)
+
+	urls, _ := repo.Search(SearchQuery{Sort: SortCreatedAt})
+	s.Assert().Len(urls, 2)
+	stats, _ := repo.GetClickStats("deleted", longAgo)
+	s.Assert().Equal(int64(0), stats.TotalClicks)
+	stats, _ = repo.GetClickStats("recent", longAgo)
+	s.Assert().Equal(int64(1), stats.TotalClicks)
+}
+
+func (s *TSuite) TestPurgeUrl_GormRepository() {
+	repo := NewGormRepository(s.DB)
+	before := time.Now()
+
+	rs := sqlmock.NewRows([]string{"short_code"})
+	s.mock.ExpectQuery(regexp.QuoteMeta("SELECT `short_code` FROM `urls` WHERE (is_deleted = ? AND deleted_at < ?) OR expiry_date < ? LIMIT 1000")).
+		WithArgs(true, before, before).
+		WillReturnRows(rs.AddRow("deleted").AddRow("expired"))
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `clicks` WHERE short_code IN (?,?)")).
+		WithArgs("deleted", "expired").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `url_histories` WHERE short_code IN (?,?)")).
+		WithArgs("deleted", "expired").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `urls` WHERE short_code IN (?,?)")).
+		WithArgs("deleted", "expired").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	s.mock.ExpectCommit()
+
+	purged, err := repo.Purge(before)
+
+	s.Assert().NoError(err)
+	s.Assert().Equal(int64(2), purged)
+}
+
+func (s *TSuite) TestSweeperStatus() {
+	repo := NewMemoryRepository()
+	sweeper := NewSweeper(repo, time.Minute)
+
+	app := fiber.New()
+	app.Get("/disabled", New(repo, Config{}).SweeperStatus)
+	app.Get("/enabled", New(repo, Config{Sweeper: sweeper}).SweeperStatus)
+
+	res, _ := app.Test(httptest.NewRequest("GET", "/disabled", nil), -1)
+	s.Assert().Equal(fiber.StatusNotFound, res.StatusCode)
+
+	_, err := sweeper.Sweep()
+	s.Require().NoError(err)
+
+	res, _ = app.Test(httptest.NewRequest("GET", "/enabled", nil), -1)
+	body, _ := ioutil.ReadAll(res.Body)
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+	s.Assert().Contains(string(body), `"runs":1`)
+	s.Assert().Contains(string(body), `"interval":"1m0s"`)
+}
+
+func (s *TSuite) TestMyUrls_OnlyOwnUrls() {
+	repo := NewMemoryRepository()
+	authService := auth.New(auth.NewMemoryRepository())
+	s.Require().NoError(authService.SetPassword("alice", "password"))
+	s.Require().NoError(authService.SetPassword("bob", "password"))
+
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Post("/", auth.Account(authService), u.Create)
+	me := app.Group("/me", auth.Account(authService), auth.RequireOwner)
+	me.Get("/urls", u.MyUrls)
+	me.Delete("/urls/:code", u.DeleteOwn)
+
+	send := func(method, path, username, body string) (int, string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		if username != "" {
+			req.SetBasicAuth(username, "password")
+		}
+		res, _ := app.Test(req, -1)
+		resBody, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(resBody)
+	}
+
+	status, _ := send("POST", "/", "alice", `{"url": "https://docs.gofiber.io/", "alias": "alice-link"}`)
+	s.Require().Equal(fiber.StatusCreated, status)
+	status, _ = send("POST", "/", "bob", `{"url": "https://docs.gofiber.io/", "alias": "bob-link"}`)
+	s.Require().Equal(fiber.StatusCreated, status)
+	status, _ = send("POST", "/", "", `{"url": "https://docs.gofiber.io/", "alias": "anonymous"}`)
+	s.Require().Equal(fiber.StatusCreated, status)
+
+	status, _ = send("GET", "/me/urls", "", "")
+	s.Assert().Equal(fiber.StatusUnauthorized, status)
+
+	status, body := send("GET", "/me/urls", "alice", "")
+	s.Assert().Equal(fiber.StatusOK, status)
+	var res ListResponse
+	s.Require().NoError(json.Unmarshal([]byte(body), &res))
+	s.Require().Len(res.Data, 1)
+	s.Assert().Equal("alice-link", res.Data[0].ShortCode)
+	s.Assert().Equal("user:alice", res.Data[0].Owner)
+
+	status, _ = send("DELETE", "/me/urls/bob-link", "alice", "")
+	s.Assert().Equal(fiber.StatusNotFound, status)
+	status, _ = send("DELETE", "/me/urls/anonymous", "alice", "")
+	s.Assert().Equal(fiber.StatusNotFound, status)
+	status, _ = send("DELETE", "/me/urls/alice-link", "alice", "")
+	s.Assert().Equal(fiber.StatusOK, status)
+
+	url, err := repo.GetByCode("alice-link")
+	s.Require().NoError(err)
+	s.Assert().True(url.IsDeleted)
+	url, err = repo.GetByCode("bob-link")
+	s.Require().NoError(err)
+	s.Assert().False(url.IsDeleted)
+}
+
+func (s *TSuite) TestBlockList_AdminEndpoints() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{BlockList: NewBlockList(repo, "", time.Minute)})
+	app := fiber.New()
+	app.Post("/", u.Create)
+	app.Get("/admin/blocklist", u.BlockRules)
+	app.Post("/admin/blocklist", u.AddBlockRule)
+	app.Delete("/admin/blocklist/:id", u.DeleteBlockRule)
+
+	send := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		res, _ := app.Test(req, -1)
+		return res.StatusCode
+	}
+	create := `{"url": "https://ads.example.com/landing"}`
+
+	s.Assert().Equal(fiber.StatusCreated, send("POST", "/", create))
+
+	s.Assert().Equal(fiber.StatusBadRequest, send("POST", "/admin/blocklist", `{"kind": "domain", "pattern": "not a domain"}`))
+	s.Assert().Equal(fiber.StatusBadRequest, send("POST", "/admin/blocklist", `{"kind": "regex", "pattern": "(unclosed"}`))
+	s.Assert().Equal(fiber.StatusCreated, send("POST", "/admin/blocklist", `{"kind": "suffix", "pattern": "example.com"}`))
+
+	// rule is applied without restart
+	s.Assert().Equal(fiber.StatusBadRequest, send("POST", "/", create))
+	s.Assert().Equal(fiber.StatusOK, send("GET", "/admin/blocklist", ""))
+
+	s.Assert().Equal(fiber.StatusOK, send("DELETE", "/admin/blocklist/1", ""))
+	s.Assert().Equal(fiber.StatusNotFound, send("DELETE", "/admin/blocklist/1", ""))
+	s.Assert().Equal(fiber.StatusCreated, send("POST", "/", create))
+}
+
+func (s *TSuite) TestThreatReport() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Get("/:code", u.Redirect)
+	app.Get("/admin/threats", u.ThreatReport)
+
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: "clean123", FullUrl: "https://docs.gofiber.io/"}))
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: "phish123", FullUrl: "https://evil.example/"}))
+	s.Require().NoError(repo.FlagThreat("phish123", "phishing.txt", true, time.Now()))
+
+	res, _ := app.Test(httptest.NewRequest("GET", "/admin/threats", nil), -1)
+	body, _ := ioutil.ReadAll(res.Body)
+	s.Assert().Equal(fiber.StatusOK, res.StatusCode)
+
+	var list ListResponse
+	s.Require().NoError(json.Unmarshal(body, &list))
+	s.Require().Len(list.Data, 1)
+	s.Assert().Equal("phish123", list.Data[0].ShortCode)
+	s.Assert().Equal("phishing.txt", list.Data[0].ThreatFeed)
+
+	// disabled url is not redirected
+	res, _ = app.Test(httptest.NewRequest("GET", "/phish123", nil), -1)
+	s.Assert().Equal(fiber.StatusGone, res.StatusCode)
+}
+
+// fakeResolver resolve urls by map, url which is not in map does not redirect
+type fakeResolver map[string]string
+
+func (r fakeResolver) Resolve(raw string) (string, error) {
+	if next, ok := r[raw]; ok {
+		return next, nil
+	}
+	return raw, nil
+}
+
+func (s *TSuite) TestCreateUrl_Shortener() {
+	resolver := fakeResolver{
+		"https://bit.ly/abc":      "https://tinyurl.com/def",
+		"https://tinyurl.com/def": "https://docs.gofiber.io/",
+		"https://bit.ly/loop":     "https://t.co/loop",
+		"https://t.co/loop":       "https://bit.ly/loop",
+		"https://bit.ly/self":     "https://sho.rt/target1",
+	}
+
+	tests := []struct {
+		name   string
+		policy string
+		url    string
+		status int
+		want   string
+	}{
+		{"should reject request host", PolicyReject, "http://example.com/target1", fiber.StatusBadRequest, ""},
+		{"should reject own host", PolicyReject, "https://www.sho.rt/target1", fiber.StatusBadRequest, ""},
+		{"should reject shortener", PolicyReject, "https://bit.ly/abc", fiber.StatusBadRequest, ""},
+		{"should not reject similar host", PolicyReject, "https://notbit.ly/abc", fiber.StatusCreated, "https://notbit.ly/abc"},
+		{"should resolve own short code", PolicyResolve, "https://sho.rt/target1", fiber.StatusCreated, "https://docs.gofiber.io/target"},
+		{"should reject unknown own short code", PolicyResolve, "https://sho.rt/unknown", fiber.StatusBadRequest, ""},
+		{"should resolve shortener chain", PolicyResolve, "https://bit.ly/abc", fiber.StatusCreated, "https://docs.gofiber.io/"},
+		{"should resolve shortener to own short code", PolicyResolve, "https://bit.ly/self", fiber.StatusCreated, "https://docs.gofiber.io/target"},
+		{"should reject loop", PolicyResolve, "https://bit.ly/loop", fiber.StatusBadRequest, ""},
+		{"should reject shortener page", PolicyResolve, "https://bit.ly/", fiber.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			repo := NewMemoryRepository()
+			s.Require().NoError(repo.Insert(&models.Url{ShortCode: "target1", FullUrl: "https://docs.gofiber.io/target"}))
+			u := New(repo, Config{
+				OwnHosts:        []string{"sho.rt"},
+				ShortenerPolicy: tt.policy,
+				Resolver:        resolver,
+			})
+			app := fiber.New()
+			app.Post("/", u.Create)
+
+			req := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(`{"url": "`+tt.url+`", "alias": "created"}`))
+			req.Header.Add("Content-Type", "application/json")
+			res, _ := app.Test(req, -1)
+			s.Assert().Equal(tt.status, res.StatusCode)
+
+			if tt.want != "" {
+				url, err := repo.GetByCode("created")
+				s.Require().NoError(err)
+				s.Assert().Equal(tt.want, url.FullUrl)
+			}
+		})
+	}
+}
+
+func (s *TSuite) TestRedirectUrl_PasswordProtected() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{UnlockAttempts: 3})
+	app := fiber.New()
+	app.Post("/", u.Create)
+	app.Get("/:code", u.Redirect)
+	app.Post("/:code", u.Redirect)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/", "alias": "secret", "password": "p4ssw0rd"}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	s.Require().Equal(fiber.StatusCreated, res.StatusCode)
+
+	url, err := repo.GetByCode("secret")
+	s.Require().NoError(err)
+	s.Assert().NotEqual("p4ssw0rd", url.PasswordHash)
+	s.Assert().NotEmpty(url.PasswordHash)
+
+	redirect := func(password string, html bool) *http.Response {
+		req := httptest.NewRequest("GET", "/secret", nil)
+		if password != "" {
+			req.Header.Add(HeaderLinkPassword, password)
+		}
+		if html {
+			req.Header.Add("Accept", "text/html")
+		}
+		res, _ := app.Test(req, -1)
+		return res
+	}
+
+	res = redirect("", false)
+	s.Assert().Equal(fiber.StatusUnauthorized, res.StatusCode)
+
+	res = redirect("", true)
+	body, _ := ioutil.ReadAll(res.Body)
+	s.Assert().Equal(fiber.StatusUnauthorized, res.StatusCode)
+	s.Assert().Contains(string(body), `<form method="post">`)
+
+	res = redirect("p4ssw0rd", false)
+	s.Assert().Equal(fiber.StatusFound, res.StatusCode)
+	s.Assert().Equal("https://docs.gofiber.io/", res.Header.Get("Location"))
+	s.Assert().Equal("no-store", res.Header.Get("Cache-Control"))
+
+	// unlock form
+	req = httptest.NewRequest("POST", "/secret", strings.NewReader("password=p4ssw0rd"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res, _ = app.Test(req, -1)
+	s.Assert().Equal(fiber.StatusSeeOther, res.StatusCode)
+
+	// lockout after wrong passwords, even the right password is rejected
+	for i := 0; i < 3; i++ {
+		s.Assert().Equal(fiber.StatusUnauthorized, redirect("wrong", false).StatusCode)
+	}
+	res = redirect("p4ssw0rd", false)
+	s.Assert().Equal(fiber.StatusTooManyRequests, res.StatusCode)
+	s.Assert().NotEmpty(res.Header.Get("Retry-After"))
+}
+
+func (s *TSuite) TestRedirectUrl_PasswordFormWithTemporaryRedirect() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+	app.Get("/:code", u.Redirect)
+	app.Post("/:code", u.Redirect)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/", "alias": "secret", "password": "p4ssw0rd", "redirect_type": 307}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	s.Require().Equal(fiber.StatusCreated, res.StatusCode)
+
+	// browser must not resend form with password to destination
+	req = httptest.NewRequest("POST", "/secret", strings.NewReader("password=p4ssw0rd"))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	res, _ = app.Test(req, -1)
+	s.Assert().Equal(fiber.StatusSeeOther, res.StatusCode)
+	s.Assert().Equal("https://docs.gofiber.io/", res.Header.Get("Location"))
+
+	// header unlock keeps redirect type of url
+	req = httptest.NewRequest("GET", "/secret", nil)
+	req.Header.Add(HeaderLinkPassword, "p4ssw0rd")
+	res, _ = app.Test(req, -1)
+	s.Assert().Equal(fiber.StatusTemporaryRedirect, res.StatusCode)
+}
+
+func (s *TSuite) TestCreateUrl_PasswordIsNotValid() {
+	u := New(NewMemoryRepository(), Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/", "password": "abc"}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+
+	s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode)
+}
+
+func (s *TSuite) TestRedirectUrl_OneTime() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{HitCounter: NewHitCounter(repo, time.Hour)})
+	app := fiber.New()
+	app.Post("/", u.Create)
+	app.Get("/:code", u.Redirect)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url": "https://docs.gofiber.io/", "alias": "once", "one_time": true}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	s.Require().Equal(fiber.StatusCreated, res.StatusCode)
+
+	res, _ = app.Test(httptest.NewRequest("GET", "/once", nil), -1)
+	s.Assert().Equal(fiber.StatusFound, res.StatusCode)
+	s.Assert().Equal("no-store", res.Header.Get(fiber.HeaderCacheControl))
+	res, _ = app.Test(httptest.NewRequest("GET", "/once", nil), -1)
+	s.Assert().Equal(fiber.StatusGone, res.StatusCode)
+
+	url, err := repo.GetByCode("once")
+	s.Require().NoError(err)
+	s.Assert().Equal(1, url.Hits)
+}
+
+func (s *TSuite) TestCreateUrl_LimitedPermanentRedirect() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+	app.Patch("/admin/urls/:code", u.Update)
+
+	for _, reqBody := range []string{
+		`{"url": "https://docs.gofiber.io/", "one_time": true, "redirect_type": 301}`,
+		`{"url": "https://docs.gofiber.io/", "max_hits": 5, "redirect_type": 308}`,
+	} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(reqBody))
+		req.Header.Add("Content-Type", "application/json")
+		res, _ := app.Test(req, -1)
+		body, _ := ioutil.ReadAll(res.Body)
+		s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode, reqBody)
+		s.Assert().Contains(string(body), ErrPermanentLimited.Error(), reqBody)
+	}
+
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: "limited", FullUrl: "https://docs.gofiber.io/", MaxHits: 5}))
+	req := httptest.NewRequest("PATCH", "/admin/urls/limited", strings.NewReader(`{"redirect_type": 301}`))
+	req.Header.Add("Content-Type", "application/json")
+	res, _ := app.Test(req, -1)
+	s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode)
+}
+
+func (s *TSuite) TestRedirectUrl_MaxHitsConcurrent() {
+	repo := NewMemoryRepository()
+	s.Require().NoError(repo.Insert(&models.Url{ShortCode: "limited", FullUrl: "https://docs.gofiber.io/", MaxHits: 5}))
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Get("/:code", u.Redirect)
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _ := app.Test(httptest.NewRequest("GET", "/limited", nil), -1)
+			statuses <- res.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	s.Assert().Equal(5, counts[fiber.StatusFound])
+	s.Assert().Equal(15, counts[fiber.StatusGone])
+}
+
+func (s *TSuite) TestRedirectUrl_MaxHitsGormRepository() {
+	repo := NewGormRepository(s.DB)
+
+	s.mock.ExpectExec(regexp.QuoteMeta("UPDATE `urls` SET `hits`=hits + 1 WHERE short_code = ? AND (max_hits = 0 OR hits < max_hits)")).
+		WithArgs("limited").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	s.Assert().Equal(ErrExhausted, repo.ConsumeHit("limited"))
+}
+
+func (s *TSuite) TestCreateUrl_MaxHitsIsNotValid() {
+	u := New(NewMemoryRepository(), Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+
+	for _, body := range []string{
+		`{"url": "https://docs.gofiber.io/", "max_hits": -1}`,
+		`{"url": "https://docs.gofiber.io/", "max_hits": 2, "one_time": true}`,
+	} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		res, _ := app.Test(req, -1)
+		s.Assert().Equal(fiber.StatusBadRequest, res.StatusCode)
+	}
+}
+
+func (s *TSuite) TestRedirectUrl_Scheduled() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{})
+	app := fiber.New()
+	app.Post("/", u.Create)
+	app.Get("/:code", u.Redirect)
+	app.Get("/admin/urls/:code?", u.List)
+
+	startsAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	create := func(body string) int {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		res, _ := app.Test(req, -1)
+		return res.StatusCode
+	}
+
+	s.Assert().Equal(fiber.StatusBadRequest, create(`{"url": "https://docs.gofiber.io/", "fallback_url": "https://gofiber.io/"}`))
+	late := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	s.Assert().Equal(fiber.StatusBadRequest, create(`{"url": "https://docs.gofiber.io/", "starts_at": "`+late+`", "expiry": 1}`))
+	s.Require().Equal(fiber.StatusCreated, create(`{"url": "https://docs.gofiber.io/", "alias": "campaign", "starts_at": "`+startsAt+`"}`))
+	s.Require().Equal(fiber.StatusCreated, create(`{"url": "https://docs.gofiber.io/", "alias": "teaser", "starts_at": "`+startsAt+`", "fallback_url": "https://gofiber.io/"}`))
+	s.Require().Equal(fiber.StatusCreated, create(`{"url": "https://docs.gofiber.io/", "alias": "running"}`))
+
+	res, _ := app.Test(httptest.NewRequest("GET", "/campaign", nil), -1)
+	body, _ := ioutil.ReadAll(res.Body)
+	s.Assert().Equal(fiber.StatusNotFound, res.StatusCode)
+	s.Assert().Contains(string(body), ErrNotYetActive.Error())
+
+	res, _ = app.Test(httptest.NewRequest("GET", "/teaser", nil), -1)
+	s.Assert().Equal(fiber.StatusFound, res.StatusCode)
+	s.Assert().Equal("https://gofiber.io/", res.Header.Get("Location"))
+
+	url, err := repo.GetByCode("teaser")
+	s.Require().NoError(err)
+	s.Assert().Equal(0, url.Hits)
+
+	res, _ = app.Test(httptest.NewRequest("GET", "/admin/urls?scheduled=true", nil), -1)
+	body, _ = ioutil.ReadAll(res.Body)
+	var list ListResponse
+	s.Require().NoError(json.Unmarshal(body, &list))
+	s.Require().Len(list.Data, 2)
+	for _, url := range list.Data {
+		s.Assert().Equal(models.StateScheduled, url.State)
+	}
+
+	res, _ = app.Test(httptest.NewRequest("GET", "/admin/urls/running", nil), -1)
+	body, _ = ioutil.ReadAll(res.Body)
+	s.Assert().Contains(string(body), `"state":"active"`)
+}
+
+func (s *TSuite) TestCreateUrl_Expiry() {
+	repo := NewMemoryRepository()
+	u := New(repo, Config{MaxExpiry: 30 * 24 * time.Hour})
+	app := fiber.New()
+	app.Post("/", u.Create)
+	app.Patch("/admin/urls/:code", u.Update)
+
+	send := func(method, target, body string) (int, string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Add("Content-Type", "application/json")
+		res, _ := app.Test(req, -1)
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+
+	at := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		alias  string
+		expiry string
+		want   time.Duration
+	}{
+		{"minutes", `"30m"`, 30 * time.Minute},
+		{"days", `"7d"`, 7 * 24 * time.Hour},
+		{"iso", `"PT12H"`, 12 * time.Hour},
+		{"hours", `24`, 24 * time.Hour},
+		{"absolute", `"` + at.Format(time.RFC3339) + `"`, time.Until(at)},
+	}
+	for _, tt := range tests {
+		status, body := send("POST", "/", `{"url": "https://docs.gofiber.io/", "alias": "`+tt.alias+`", "expiry": `+tt.expiry+`}`)
+		s.Require().Equal(fiber.StatusCreated, status, body)
+
+		url, err := repo.GetByCode(tt.alias)
+		s.Require().NoError(err)
+		s.Require().NotNil(url.ExpiryDate)
+		s.Assert().WithinDuration(time.Now().Add(tt.want), *url.ExpiryDate, 5*time.Second, tt.alias)
+	}
+
+	for expiry, message := range map[string]string{
+		`"10s"`:                   "expiry: must be at least 1m0s from now",
+		`"P1Y"`:                   "expiry: must be at most 720h0m0s from now",
+		`"2021-03-01T09:00:00Z"`:  "expiry: must be at least 1m0s from now",
+		`"next week"`:             ErrInvalidExpiry.Error(),
+		`-24`:                     ErrNegativeExpiry.Error(),
+		`"99999999999999999999d"`: ErrExpiryTooLarge.Error(),
+		`"PT2562048H"`:            ErrExpiryTooLarge.Error(),
+	} {
+		status, body := send("POST", "/", `{"url": "https://docs.gofiber.io/", "expiry": `+expiry+`}`)
+		s.Assert().Equal(fiber.StatusBadRequest, status, expiry)
+		s.Assert().Contains(body, message, expiry)
+	}
+
+	status, _ := send("PATCH", "/admin/urls/minutes", `{"expiry": "P1D"}`)
+	s.Require().Equal(fiber.StatusOK, status)
+	url, err := repo.GetByCode("minutes")
+	s.Require().NoError(err)
+	s.Assert().WithinDuration(time.Now().AddDate(0, 0, 1), *url.ExpiryDate, 5*time.Second)
+
+	status, body := send("PATCH", "/admin/urls/minutes", `{"expiry": "1s"}`)
+	s.Assert().Equal(fiber.StatusBadRequest, status)
+	s.Assert().Contains(body, "expiry: ")
+}
+
 func (s *TSuite) AfterTest(_, _ string) {
 	require.NoError(s.T(), s.mock.ExpectationsWereMet())
 }
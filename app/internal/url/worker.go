@@ -0,0 +1,42 @@
+package url
+
+import (
+	"time"
+)
+
+// worker run a task every interval in background until it is stopped
+type worker struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newWorker() worker {
+	return worker{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// run call task every interval until stopWait
+func (w *worker) run(interval time.Duration, task func()) {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				task()
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopWait stop background task and wait for the running one to finish
+func (w *worker) stopWait() {
+	close(w.stop)
+	<-w.done
+}
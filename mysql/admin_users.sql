@@ -0,0 +1,18 @@
+--
+-- Database: `rabbit`
+--
+
+-- --------------------------------------------------------
+
+--
+-- Table structure for table `admin_users`
+--
+
+CREATE TABLE `admin_users` (
+  `username` varchar(64) NOT NULL,
+  `password_hash` varchar(60) NOT NULL,
+  `role` varchar(16) NOT NULL DEFAULT 'viewer',
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`username`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,24 @@
+--
+-- Database: `rabbit`
+--
+
+-- --------------------------------------------------------
+
+--
+-- Table structure for table `block_rules`
+--
+
+CREATE TABLE `block_rules` (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `kind` varchar(16) NOT NULL,
+  `pattern` varchar(255) NOT NULL,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+--
+-- Dumping data for table `block_rules`
+--
+
+INSERT INTO `block_rules` (`kind`, `pattern`, `created_at`) VALUES
+('suffix', 'facebook.com', NOW());
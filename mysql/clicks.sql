@@ -0,0 +1,21 @@
+--
+-- Database: `rabbit`
+--
+
+-- --------------------------------------------------------
+
+--
+-- Table structure for table `clicks`
+--
+
+CREATE TABLE `clicks` (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `short_code` varchar(32) NOT NULL,
+  `clicked_at` datetime NOT NULL,
+  `referrer` varchar(2000) NOT NULL DEFAULT '',
+  `user_agent` varchar(512) NOT NULL DEFAULT '',
+  `ip_hash` char(64) NOT NULL,
+  `accept_language` varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  KEY `idx_clicks_short_code` (`short_code`, `clicked_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
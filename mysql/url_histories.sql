@@ -0,0 +1,20 @@
+--
+-- Database: `rabbit`
+--
+
+-- --------------------------------------------------------
+
+--
+-- Table structure for table `url_histories`
+--
+
+CREATE TABLE `url_histories` (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `short_code` varchar(32) NOT NULL,
+  `full_url` varchar(2000) NOT NULL,
+  `expiry_date` datetime,
+  `redirect_type` smallint NOT NULL DEFAULT '302',
+  `changed_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_url_histories_short_code` (`short_code`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -30,9 +30,23 @@ SET time_zone = "+00:00";
 CREATE TABLE `urls` (
   `short_code` varchar(32) NOT NULL,
   `full_url` varchar(2000) NOT NULL,
+  `url_hash` char(64) NOT NULL DEFAULT '',
+  `starts_at` datetime,
   `expiry_date` datetime,
   `hits` int NOT NULL,
-  `is_deleted` tinyint(1) NOT NULL DEFAULT '0'
+  `max_hits` int NOT NULL DEFAULT '0',
+  `is_deleted` tinyint(1) NOT NULL DEFAULT '0',
+  `deleted_at` datetime,
+  `redirect_type` smallint NOT NULL DEFAULT '302',
+  `status` varchar(16) NOT NULL DEFAULT 'active',
+  `api_key_id` bigint unsigned,
+  `owner` varchar(100) NOT NULL DEFAULT '',
+  `threat_feed` varchar(255) NOT NULL DEFAULT '',
+  `flagged_at` datetime,
+  `is_disabled` tinyint(1) NOT NULL DEFAULT '0',
+  `password_hash` varchar(60) NOT NULL DEFAULT '',
+  `fallback_url` varchar(2000) NOT NULL DEFAULT '',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 
 --
@@ -43,7 +57,14 @@ CREATE TABLE `urls` (
 -- Indexes for table `urls`
 --
 ALTER TABLE `urls`
-  ADD PRIMARY KEY (`short_code`);
+  ADD PRIMARY KEY (`short_code`),
+  ADD KEY `idx_urls_url_hash` (`url_hash`),
+  ADD KEY `idx_urls_created_at` (`created_at`, `short_code`),
+  ADD KEY `idx_urls_hits` (`hits`, `short_code`),
+  ADD KEY `idx_urls_expiry_date` (`expiry_date`, `short_code`),
+  ADD KEY `idx_urls_api_key_id` (`api_key_id`),
+  ADD KEY `idx_urls_owner` (`owner`, `created_at`, `short_code`),
+  ADD KEY `idx_urls_threat_feed` (`threat_feed`);
 COMMIT;
 
 /*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;